@@ -1,13 +1,15 @@
 package client
 
 import (
+	"bytes"
 	"io"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
 	"github.com/koki/json"
-	"github.com/koki/json/jsonutil"
 	"github.com/koki/short/converter"
 	"github.com/koki/short/parser"
-	"github.com/koki/short/yaml"
 	serrors "github.com/koki/structurederrors"
 )
 
@@ -33,22 +35,13 @@ func ConvertKokiStreams(kokiStreams []io.ReadCloser) ([]interface{}, error) {
 func ConvertKokiMaps(objs []map[string]interface{}) ([]interface{}, error) {
 	convertedObjs := make([]interface{}, len(objs))
 	for i, obj := range objs {
-		// 1. Parse.
-		parsedObj, err := parser.ParseKokiNativeObject(obj)
+		// 1. Parse, and check for unparsed fields.
+		parsedObj, err := ParseKokiMap(obj)
 		if err != nil {
 			return nil, err
 		}
 
-		// 2. Check for unparsed fields--potential typos.
-		extraneousPaths, err := jsonutil.ExtraneousFieldPaths(obj, parsedObj)
-		if err != nil {
-			return nil, serrors.ContextualizeErrorf(err, "checking for extraneous fields in input")
-		}
-		if len(extraneousPaths) > 0 {
-			return nil, &jsonutil.ExtraneousFieldsError{Paths: extraneousPaths}
-		}
-
-		// 3. Convert.
+		// 2. Convert.
 		convertedObj, err := converter.DetectAndConvertFromKokiObj(parsedObj)
 		if err != nil {
 			return nil, err
@@ -72,22 +65,13 @@ func ConvertKubeStreams(kubeStreams []io.ReadCloser) ([]interface{}, error) {
 func ConvertKubeMaps(objs []map[string]interface{}) ([]interface{}, error) {
 	convertedObjs := make([]interface{}, len(objs))
 	for i, obj := range objs {
-		// 1. Parse.
-		parsedObj, err := parser.ParseSingleKubeNative(obj)
+		// 1. Parse, and check for unparsed fields.
+		parsedObj, err := ParseKubeMap(obj)
 		if err != nil {
 			return nil, err
 		}
 
-		// 2. Check for unparsed fields--potential typos.
-		extraneousPaths, err := jsonutil.ExtraneousFieldPaths(obj, parsedObj)
-		if err != nil {
-			return nil, serrors.ContextualizeErrorf(err, "checking for extraneous fields in input")
-		}
-		if len(extraneousPaths) > 0 {
-			return nil, &jsonutil.ExtraneousFieldsError{Paths: extraneousPaths}
-		}
-
-		// 3. Convert.
+		// 2. Convert.
 		convertedObj, err := converter.DetectAndConvertFromKubeObj(parsedObj)
 		if err != nil {
 			return nil, err
@@ -98,30 +82,34 @@ func ConvertKubeMaps(objs []map[string]interface{}) ([]interface{}, error) {
 	return convertedObjs, nil
 }
 
-// ConvertEitherStreamsToKube either Koki or Kube to just Kube objects.
-func ConvertEitherStreamsToKube(eitherStreams []io.ReadCloser) ([]interface{}, error) {
-	objs, err := parser.ParseStreams(eitherStreams)
-	if err != nil {
-		return nil, err
+// WrapObjsInList packs Kube objects into a single v1 List.
+func WrapObjsInList(objs []interface{}) (*metav1.List, error) {
+	list := &metav1.List{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "List",
+		},
+		Items: make([]runtime.RawExtension, len(objs)),
 	}
 
-	kubeObjs := make([]interface{}, len(objs))
 	for i, obj := range objs {
-		kubeObjs[i], err = converter.ConvertOneToKubeNative(obj)
-		if err == nil {
-			continue
-		}
-
-		kubeObjs[i], err = parser.ParseSingleKubeNative(obj)
+		b, err := json.Marshal(obj)
 		if err != nil {
-			return nil, serrors.InvalidValueErrorf(obj, "couldn't parse as Kube or Koki resource")
+			return nil, serrors.InvalidValueContextErrorf(err, obj, "couldn't serialize list item as json")
 		}
+		list.Items[i] = runtime.RawExtension{Raw: b}
 	}
 
-	return kubeObjs, nil
+	return list, nil
 }
 
 func WriteObjsToYamlStream(objs []interface{}, yamlStream io.Writer) error {
+	return WriteCommentedObjsToYamlStream(objs, nil, yamlStream)
+}
+
+// WriteCommentedObjsToYamlStream writes objs like WriteObjsToYamlStream, with the comments
+// of the documents they were converted from.
+func WriteCommentedObjsToYamlStream(objs []interface{}, comments ObjComments, yamlStream io.Writer) error {
 	var err error
 	for i, obj := range objs {
 		if i > 0 {
@@ -131,9 +119,18 @@ func WriteObjsToYamlStream(objs []interface{}, yamlStream io.Writer) error {
 			}
 		}
 
-		b, err := yaml.Marshal(obj)
-		if err != nil {
-			return serrors.InvalidValueErrorf(obj, "couldn't serialize as yaml")
+		var b []byte
+		if doc, ok := obj.(*SourceDocument); ok && len(doc.Source) > 0 {
+			// The source already has its comments.
+			b = doc.Source
+			if !bytes.HasSuffix(b, []byte("\n")) {
+				b = append(append([]byte{}, b...), '\n')
+			}
+		} else {
+			b, err = MarshalYAMLWithComments(obj, comments.For(obj))
+			if err != nil {
+				return err
+			}
 		}
 		_, err = yamlStream.Write(b)
 		if err != nil {
@@ -0,0 +1,430 @@
+package client
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/koki/short/parser"
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+/*
+
+Comments are carried from an input document to the object it's converted to.
+
+Each comment is attached to the output field that its input field converts to, using the
+commentRules for the object's kind. Fields with the same syntax in both formats (e.g. labels)
+are mapped with everything under them. A comment on a field without a rule moves to its
+closest mapped ancestor (e.g. a container port's fields become one koki port), and is
+prefixed with the rest of its path, so it still says what it's about. If the ancestor is a
+scalar (e.g. an env var's value collapses into NAME=value), the comment is left as it is, since
+it's on the same line as the value. Comments that can't be mapped at all go to the top of the object.
+
+*/
+
+// DocumentComments are the comments of an input document.
+type DocumentComments struct {
+	// Format is the syntax of the input document.
+	Format Format
+	// Resource is the kind of a kube-native document, or the root key of a koki document.
+	Resource string
+
+	Comments []parser.Comment
+}
+
+// NewDocumentComments collects the comments of an input document, or returns nil if it has none.
+func NewDocumentComments(obj map[string]interface{}, comments []parser.Comment) *DocumentComments {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	format, resource, err := resourceOf(obj)
+	if err != nil {
+		return nil
+	}
+
+	return &DocumentComments{
+		Format:   format,
+		Resource: resource,
+		Comments: comments,
+	}
+}
+
+func resourceOf(obj map[string]interface{}) (Format, string, error) {
+	format, err := DetectFormat(obj)
+	if err != nil {
+		return "", "", err
+	}
+
+	if format == FormatKube {
+		return format, obj["kind"].(string), nil
+	}
+
+	for key := range obj {
+		if !kokiModuleKeys[key] {
+			return format, key, nil
+		}
+	}
+
+	return format, "", nil
+}
+
+// ObjComments holds the input comments for converted objects, by object.
+type ObjComments map[interface{}]*DocumentComments
+
+// Add records the comments for obj. Only pointers can be looked up by identity, so the
+// comments of other objects aren't kept.
+func (c ObjComments) Add(obj interface{}, comments *DocumentComments) {
+	if comments == nil || obj == nil || reflect.TypeOf(obj).Kind() != reflect.Ptr {
+		return
+	}
+
+	c[obj] = comments
+}
+
+// For finds the comments for obj, or returns nil if it has none.
+func (c ObjComments) For(obj interface{}) *DocumentComments {
+	if obj == nil || reflect.TypeOf(obj).Kind() != reflect.Ptr {
+		return nil
+	}
+
+	return c[obj]
+}
+
+// Merge adds all the comments in other to c.
+func (c ObjComments) Merge(other ObjComments) {
+	for obj, comments := range other {
+		c[obj] = comments
+	}
+}
+
+// MarshalYAMLWithComments marshals obj to yaml, like WriteObjsToYamlStream, and adds the comments
+// of the document it was converted from.
+func MarshalYAMLWithComments(obj interface{}, comments *DocumentComments) ([]byte, error) {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, serrors.InvalidValueErrorf(obj, "couldn't serialize as yaml")
+	}
+	if comments == nil || len(comments.Comments) == 0 {
+		return b, nil
+	}
+
+	outObj := map[string]interface{}{}
+	err = yaml.Unmarshal(b, &outObj)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, string(b), "reading marshalled yaml")
+	}
+	outFormat, outResource, err := resourceOf(outObj)
+	if err != nil {
+		// e.g. a kube-native list. Its items aren't mapped.
+		return b, nil
+	}
+
+	maps, err := parser.ReadSourceMaps("", b)
+	if err != nil || len(maps) != 1 {
+		return b, nil
+	}
+
+	mapper := &commentMapper{
+		from: comments.Format,
+		out:  maps[0],
+	}
+	switch {
+	case comments.Format == outFormat:
+		mapper.rules = samePathRules
+	case outFormat == FormatKoki:
+		mapper.rules = commentRules(comments.Resource, outResource)
+	default:
+		mapper.rules = commentRules(outResource, comments.Resource)
+	}
+
+	return mapper.addComments(b, comments.Comments), nil
+}
+
+// commentRule maps a field in kube-native syntax to the same field in koki syntax.
+// A "*" segment matches a list index, and maps to the same index.
+type commentRule struct {
+	kube, koki string
+
+	// descend maps the fields under the field too, because they have the same names in both syntaxes.
+	descend bool
+}
+
+// samePathRules keep every field's path, for output in the same syntax as the input.
+var samePathRules = []commentRule{{descend: true}}
+
+// commentRules are the rules for converting between a kube-native kind and its koki root key.
+func commentRules(kind, key string) []commentRule {
+	rules := []commentRule{
+		{kube: "", koki: key},
+		{kube: "apiVersion", koki: key + ".version"},
+		{kube: "metadata.name", koki: key + ".name"},
+		{kube: "metadata.namespace", koki: key + ".namespace"},
+		{kube: "metadata.labels", koki: key + ".labels", descend: true},
+		{kube: "metadata.annotations", koki: key + ".annotations", descend: true},
+	}
+
+	switch kind {
+	case "Pod":
+		rules = append(rules, podSpecCommentRules("spec", key)...)
+	case "Deployment", "DaemonSet", "Job", "ReplicaSet", "ReplicationController", "StatefulSet":
+		rules = append(rules,
+			commentRule{kube: "spec.replicas", koki: key + ".replicas"},
+			commentRule{kube: "spec.selector", koki: key + ".selector"},
+		)
+		rules = append(rules, podSpecCommentRules("spec.template.spec", key)...)
+	case "Service":
+		rules = append(rules,
+			commentRule{kube: "spec.selector", koki: key + ".selector", descend: true},
+			commentRule{kube: "spec.ports", koki: key + ".ports"},
+			commentRule{kube: "spec.ports.*", koki: key + ".ports.*"},
+			// A single unnamed port.
+			commentRule{kube: "spec.ports.0", koki: key + ".port"},
+			commentRule{kube: "spec.type", koki: key + ".type"},
+			commentRule{kube: "spec.clusterIP", koki: key + ".cluster_ip"},
+			commentRule{kube: "spec.externalName", koki: key + ".cname"},
+			commentRule{kube: "spec.loadBalancerIP", koki: key + ".lb_ip"},
+		)
+	case "ConfigMap", "Secret":
+		rules = append(rules,
+			commentRule{kube: "data", koki: key + ".data", descend: true},
+			commentRule{kube: "stringData", koki: key + ".string_data", descend: true},
+			commentRule{kube: "type", koki: key + ".type"},
+		)
+	}
+
+	return rules
+}
+
+// podSpecCommentRules map the fields of a kube-native pod spec at kubePath to the koki pod template under key.
+func podSpecCommentRules(kubePath, key string) []commentRule {
+	rules := []commentRule{}
+	for _, field := range podSpecCommentFields {
+		rules = append(rules, commentRule{kube: kubePath + "." + field[0], koki: key + "." + field[1]})
+	}
+
+	for _, list := range [][2]string{{"containers", "containers"}, {"initContainers", "init_containers"}} {
+		kubeContainer := kubePath + "." + list[0] + ".*"
+		kokiContainer := key + "." + list[1] + ".*"
+		rules = append(rules,
+			commentRule{kube: kubePath + "." + list[0], koki: key + "." + list[1]},
+			commentRule{kube: kubeContainer, koki: kokiContainer},
+		)
+		for _, field := range containerCommentFields {
+			rules = append(rules, commentRule{
+				kube:    kubeContainer + "." + field[0],
+				koki:    kokiContainer + "." + field[1],
+				descend: field[0] == "command" || field[0] == "args",
+			})
+		}
+	}
+
+	return rules
+}
+
+// podSpecCommentFields are pairs of kube-native and koki pod fields.
+var podSpecCommentFields = [][2]string{
+	{"restartPolicy", "restart_policy"},
+	{"serviceAccountName", "account"},
+	{"nodeName", "node"},
+	{"hostname", "hostname"},
+	{"dnsPolicy", "dns_policy"},
+	{"terminationGracePeriodSeconds", "termination_grace_period"},
+	{"activeDeadlineSeconds", "active_deadline"},
+	{"schedulerName", "scheduler_name"},
+	{"hostNetwork", "host_mode"},
+	{"hostPID", "host_mode"},
+	{"hostIPC", "host_mode"},
+	{"imagePullSecrets", "registry_secrets"},
+	{"affinity", "affinity"},
+	{"tolerations", "tolerations"},
+	{"volumes", "volumes"},
+}
+
+// containerCommentFields are pairs of kube-native and koki container fields.
+var containerCommentFields = [][2]string{
+	{"name", "name"},
+	{"image", "image"},
+	{"command", "command"},
+	{"args", "args"},
+	{"workingDir", "wd"},
+	{"imagePullPolicy", "pull"},
+	{"env", "env"},
+	{"env.*", "env.*"},
+	{"ports", "expose"},
+	{"ports.*", "expose.*"},
+	{"ports.*.containerPort", "expose.*"},
+	{"resources.limits.cpu", "cpu.max"},
+	{"resources.requests.cpu", "cpu.min"},
+	{"resources.limits.memory", "mem.max"},
+	{"resources.requests.memory", "mem.min"},
+	{"livenessProbe", "liveness_probe"},
+	{"readinessProbe", "readiness_probe"},
+	{"volumeMounts", "volume"},
+	{"volumeMounts.*", "volume.*"},
+	{"securityContext.privileged", "privileged"},
+	{"lifecycle.postStart", "on_start"},
+	{"lifecycle.preStop", "pre_stop"},
+}
+
+type commentMapper struct {
+	rules []commentRule
+	// from is the syntax of the input.
+	from Format
+	// out locates the fields of the output.
+	out *parser.SourceMap
+}
+
+// place finds the output field for the input field at path, and the part of path that
+// doesn't have a field of its own in the output. The output field is "" for the top.
+func (m *commentMapper) place(path string) (string, []string) {
+	segments := []string{}
+	if len(path) > 0 {
+		segments = strings.Split(path, ".")
+	}
+
+	// Try path, then each of its ancestors.
+	for n := len(segments); n > 0; n-- {
+		for _, rule := range m.rules {
+			from, to := rule.kube, rule.koki
+			if m.from == FormatKoki {
+				from, to = rule.koki, rule.kube
+			}
+
+			pattern := []string{}
+			if len(from) > 0 {
+				pattern = strings.Split(from, ".")
+			}
+			if len(pattern) > n || (len(pattern) < n && !rule.descend) {
+				continue
+			}
+			indexes, ok := matchCommentPattern(pattern, segments[:len(pattern)])
+			if !ok {
+				continue
+			}
+
+			target := fillCommentPattern(to, indexes, segments[len(pattern):n])
+			if len(target) == 0 {
+				return "", segments[n:]
+			}
+			if _, ok := m.out.Find(target); ok {
+				return target, segments[n:]
+			}
+		}
+	}
+
+	return "", segments
+}
+
+func matchCommentPattern(pattern, segments []string) ([]string, bool) {
+	indexes := []string{}
+	for i, segment := range pattern {
+		if segment == "*" {
+			if _, err := strconv.Atoi(segments[i]); err != nil {
+				return nil, false
+			}
+			indexes = append(indexes, segments[i])
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return indexes, true
+}
+
+func fillCommentPattern(pattern string, indexes, rest []string) string {
+	segments := []string{}
+	if len(pattern) > 0 {
+		segments = strings.Split(pattern, ".")
+	}
+	for i, segment := range segments {
+		if segment == "*" && len(indexes) > 0 {
+			segments[i], indexes = indexes[0], indexes[1:]
+		}
+	}
+
+	return strings.Join(append(segments, rest...), ".")
+}
+
+// addComments writes each comment into the marshalled yaml b, at its output field.
+func (m *commentMapper) addComments(b []byte, comments []parser.Comment) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	heads := map[int][]string{}
+	lineComments := map[int][]string{}
+	feet := []string{}
+	for _, comment := range comments {
+		target, rest := m.place(comment.Path)
+		text := comment.Text
+		if len(rest) > 1 || (len(rest) == 1 && !m.isScalar(target)) {
+			text = prefixComment(text, strings.Join(rest, "."))
+		}
+
+		line := 0
+		if len(target) > 0 {
+			pos, _ := m.out.Find(target)
+			line = pos.Line - 1
+		}
+
+		switch {
+		case comment.Kind == parser.FootComment && line == 0 && len(rest) == 0:
+			// A comment after the whole object stays at its end.
+			feet = append(feet, text)
+		case comment.Kind == parser.LineComment && len(target) > 0 && canEndWithComment(lines, line):
+			lineComments[line] = append(lineComments[line], text)
+		default:
+			heads[line] = append(heads[line], text)
+		}
+	}
+
+	result := []string{}
+	for i, line := range lines {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		for _, head := range heads[i] {
+			for _, headLine := range strings.Split(head, "\n") {
+				result = append(result, indent+headLine)
+			}
+		}
+
+		if comments, ok := lineComments[i]; ok {
+			line = line + " " + strings.Join(comments, " ")
+		}
+		result = append(result, line)
+	}
+	result = append(result, feet...)
+
+	return []byte(strings.Join(result, "\n") + "\n")
+}
+
+// isScalar is true if the output field at path has a scalar value, so its fields collapsed into it.
+func (m *commentMapper) isScalar(path string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for other := range m.out.Positions() {
+		if strings.HasPrefix(other, path+".") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// prefixComment says which field a comment is about, when it's attached to an ancestor of that field.
+func prefixComment(text, path string) string {
+	return "# " + path + ":" + strings.TrimPrefix(text, "#")
+}
+
+// canEndWithComment is false if the value on line i continues on the next line (e.g. a long string).
+func canEndWithComment(lines []string, i int) bool {
+	if strings.HasSuffix(lines[i], ":") || i+1 >= len(lines) {
+		return true
+	}
+
+	// The column where the line's key or value starts, after any list item dashes.
+	column := len(lines[i]) - len(strings.TrimLeft(lines[i], " -"))
+	next := lines[i+1]
+	return len(next)-len(strings.TrimLeft(next, " ")) <= column
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/koki/short/parser"
+)
+
+func TestMarshalYAMLWithComments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		convert  func([]map[string]interface{}) ([]interface{}, error)
+		expected string
+	}{
+		{
+			name: "kube to koki",
+			input: `# the web frontend
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web # keep in sync with the service
+spec:
+  containers:
+  # the main app
+  - name: web
+    # pinned
+    image: nginx:1.13
+    resources:
+      limits:
+        cpu: 500m # measured at peak
+    ports:
+    - containerPort: 80 # http
+  # resolve with the node's config
+  dnsPolicy: Default
+  securityContext:
+    fsGroup: 2000 # not mapped
+
+# the end
+`,
+			convert: ConvertKubeMaps,
+			expected: `# the web frontend
+# spec.securityContext.fsGroup: not mapped
+pod:
+  containers:
+  # the main app
+  - cpu:
+      max: 500m # measured at peak
+    expose:
+    - 80 # http
+    # pinned
+    image: nginx:1.13
+    name: web
+  # resolve with the node's config
+  dns_policy: default
+  fs_gid: 2000
+  name: web # keep in sync with the service
+  version: v1
+# the end
+`,
+		},
+		{
+			name: "fields that collapse into a scalar",
+			input: `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: web
+    image: nginx
+    env:
+    - name: MODE
+      value: fast # until the next release
+    ports:
+    - containerPort: 53
+      protocol: UDP # for dns
+    - name: metrics
+      containerPort: 9090
+      hostPort: 9090 # scraped from the node
+`,
+			convert: ConvertKubeMaps,
+			expected: `pod:
+  containers:
+  - env:
+    - MODE=fast # until the next release
+    expose:
+    - udp://53 # for dns
+    - metrics: 9090:9090 # hostPort: scraped from the node
+    image: nginx
+    name: web
+  name: web
+  version: v1
+`,
+		},
+		{
+			name: "koki to kube",
+			input: `# public entrypoint
+service:
+  version: v1
+  name: web
+  port: 80:8080
+  selector:
+    app: web # matches the deployment
+`,
+			convert: ConvertKokiMaps,
+			expected: `# public entrypoint
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+    protocol: TCP
+    targetPort: 8080
+  selector:
+    app: web # matches the deployment
+  type: ClusterIP
+`,
+		},
+		{
+			name: "same syntax",
+			input: `config_map:
+  version: v1
+  name: settings
+  data:
+    # seconds
+    timeout: "30"
+`,
+			convert: func(objs []map[string]interface{}) ([]interface{}, error) {
+				return ConvertEitherMapsToKoki(objs)
+			},
+			expected: `config_map:
+  data:
+    # seconds
+    timeout: "30"
+  name: settings
+  version: v1
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		maps, err := parser.ReadSourceMaps("a.yaml", []byte(testCase.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs, err := parser.ParseStreams([]io.ReadCloser{ioutil.NopCloser(bytes.NewBufferString(testCase.input))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		comments := NewDocumentComments(objs[0], maps[0].Comments)
+
+		converted, err := testCase.convert(objs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := MarshalYAMLWithComments(converted[0], comments)
+		if err != nil {
+			t.Errorf("%s: %s", testCase.name, err.Error())
+			continue
+		}
+		if string(b) != testCase.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", testCase.name, testCase.expected, string(b))
+		}
+	}
+}
+
+func TestObjComments(t *testing.T) {
+	comments := &DocumentComments{Format: FormatKube, Resource: "Pod"}
+	obj := &struct{}{}
+	objComments := ObjComments{}
+	objComments.Add(obj, comments)
+	// Only pointers are kept, since other values can't be told apart.
+	objComments.Add(map[string]interface{}{}, comments)
+
+	if objComments.For(obj) != comments {
+		t.Error("expected the comments for obj")
+	}
+	if objComments.For(map[string]interface{}{}) != nil || len(objComments) != 1 {
+		t.Errorf("expected only comments for pointers, got %v", objComments)
+	}
+
+	var empty ObjComments
+	if empty.For(obj) != nil {
+		t.Error("expected no comments from an empty ObjComments")
+	}
+	if !strings.HasPrefix(prefixComment("# http", "containerPort"), "# containerPort: http") {
+		t.Errorf("unexpected prefixed comment %s", prefixComment("# http", "containerPort"))
+	}
+}
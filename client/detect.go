@@ -0,0 +1,165 @@
+package client
+
+import (
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/koki/json/jsonutil"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// Format is the syntax of a manifest.
+type Format string
+
+const (
+	FormatKube Format = "kube"
+	FormatKoki Format = "koki"
+)
+
+// kokiModuleKeys can appear next to the resource in a koki document (see the imports package).
+var kokiModuleKeys = map[string]bool{
+	"imports": true,
+	"params":  true,
+}
+
+// DetectFormat tells whether a document is kube-native (it has apiVersion and kind)
+// or koki (it has a single key naming its resource, and maybe imports and params).
+func DetectFormat(obj map[string]interface{}) (Format, error) {
+	_, hasAPIVersion := obj["apiVersion"].(string)
+	_, hasKind := obj["kind"].(string)
+	if hasAPIVersion && hasKind {
+		return FormatKube, nil
+	}
+
+	resourceKeys := 0
+	for key := range obj {
+		if !kokiModuleKeys[key] {
+			resourceKeys++
+		}
+	}
+	if resourceKeys == 1 && !hasKind {
+		return FormatKoki, nil
+	}
+
+	return "", serrors.InvalidValueErrorf(obj, "couldn't detect the format of the document. expected apiVersion and kind (kube) or a single resource key (koki)")
+}
+
+// ParseKubeMap parses a kube-native document, and checks it for unparsed fields (potential typos).
+func ParseKubeMap(obj map[string]interface{}) (runtime.Object, error) {
+	parsedObj, err := parser.ParseSingleKubeNative(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkExtraneousFields(obj, parsedObj)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsedObj, nil
+}
+
+// ParseKokiMap parses a koki document, and checks it for unparsed fields (potential typos).
+func ParseKokiMap(obj map[string]interface{}) (interface{}, error) {
+	parsedObj, err := parser.ParseKokiNativeObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkExtraneousFields(obj, parsedObj)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsedObj, nil
+}
+
+func checkExtraneousFields(obj map[string]interface{}, parsedObj interface{}) error {
+	extraneousPaths, err := jsonutil.ExtraneousFieldPaths(obj, parsedObj)
+	if err != nil {
+		return serrors.ContextualizeErrorf(err, "checking for extraneous fields in input")
+	}
+	if len(extraneousPaths) > 0 {
+		return &jsonutil.ExtraneousFieldsError{Paths: extraneousPaths}
+	}
+
+	return nil
+}
+
+// ConvertEitherStreamsToKoki converts Kube objects to Koki, and passes Koki objects through.
+func ConvertEitherStreamsToKoki(eitherStreams []io.ReadCloser) ([]interface{}, error) {
+	objs, err := parser.ParseStreams(eitherStreams)
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertEitherMapsToKoki(objs)
+}
+
+// ConvertEitherMapsToKoki converts Kube objects to Koki, and passes Koki objects through.
+// Koki modules aren't evaluated--use the imports package for modules with imports.
+func ConvertEitherMapsToKoki(objs []map[string]interface{}) ([]interface{}, error) {
+	kokiObjs := make([]interface{}, len(objs))
+	for i, obj := range objs {
+		format, err := DetectFormat(obj)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "document %d", i)
+		}
+
+		if format == FormatKoki {
+			kokiObjs[i], err = ParseKokiMap(obj)
+			if err != nil {
+				return nil, serrors.ContextualizeErrorf(err, "document %d", i)
+			}
+			continue
+		}
+
+		converted, err := ConvertKubeMaps([]map[string]interface{}{obj})
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "document %d", i)
+		}
+		kokiObjs[i] = converted[0]
+	}
+
+	return kokiObjs, nil
+}
+
+// ConvertEitherStreamsToKube converts Koki objects to Kube, and passes Kube objects through.
+func ConvertEitherStreamsToKube(eitherStreams []io.ReadCloser) ([]interface{}, error) {
+	objs, err := parser.ParseStreams(eitherStreams)
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertEitherMapsToKube(objs)
+}
+
+// ConvertEitherMapsToKube converts Koki objects to Kube, and passes Kube objects through.
+// Koki modules aren't evaluated--use the imports package for modules with imports.
+func ConvertEitherMapsToKube(objs []map[string]interface{}) ([]interface{}, error) {
+	kubeObjs := make([]interface{}, len(objs))
+	for i, obj := range objs {
+		format, err := DetectFormat(obj)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "document %d", i)
+		}
+
+		if format == FormatKube {
+			kubeObjs[i], err = ParseKubeMap(obj)
+			if err != nil {
+				return nil, serrors.ContextualizeErrorf(err, "document %d", i)
+			}
+			continue
+		}
+
+		converted, err := ConvertKokiMaps([]map[string]interface{}{obj})
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "document %d", i)
+		}
+		kubeObjs[i] = converted[0]
+	}
+
+	return kubeObjs, nil
+}
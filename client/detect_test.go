@@ -0,0 +1,141 @@
+package client
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/koki/short/types"
+)
+
+func TestDetectFormat(t *testing.T) {
+	testCases := []struct {
+		obj      map[string]interface{}
+		expected Format
+	}{
+		{
+			obj:      map[string]interface{}{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{}},
+			expected: FormatKube,
+		},
+		{
+			obj:      map[string]interface{}{"pod": map[string]interface{}{"name": "web"}},
+			expected: FormatKoki,
+		},
+		{
+			obj: map[string]interface{}{
+				"imports": []interface{}{},
+				"params":  []interface{}{},
+				"pod":     map[string]interface{}{"name": "web"},
+			},
+			expected: FormatKoki,
+		},
+		{
+			// A kind without an apiVersion is neither.
+			obj: map[string]interface{}{"kind": "Pod"},
+		},
+		{
+			obj: map[string]interface{}{"pod": map[string]interface{}{}, "service": map[string]interface{}{}},
+		},
+		{
+			obj: map[string]interface{}{},
+		},
+	}
+
+	for i, testCase := range testCases {
+		actual, err := DetectFormat(testCase.obj)
+		if len(testCase.expected) == 0 {
+			if err == nil {
+				t.Errorf("case %d: expected an error, got %s", i, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error %s", i, err.Error())
+			continue
+		}
+		if actual != testCase.expected {
+			t.Errorf("case %d: expected %s, got %s", i, testCase.expected, actual)
+		}
+	}
+}
+
+func TestConvertEitherStreamsToKoki(t *testing.T) {
+	stream := ioutil.NopCloser(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kube
+---
+config_map:
+  name: koki
+  version: v1
+`))
+
+	objs, err := ConvertEitherStreamsToKoki([]io.ReadCloser{stream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(objs))
+	}
+
+	for i, name := range []string{"kube", "koki"} {
+		wrapper, ok := objs[i].(*types.ConfigMapWrapper)
+		if !ok {
+			t.Errorf("object %d: expected a koki config map, got %T", i, objs[i])
+			continue
+		}
+		if wrapper.ConfigMap.Name != name {
+			t.Errorf("object %d: expected name (%s), got (%s)", i, name, wrapper.ConfigMap.Name)
+		}
+	}
+
+	// Unparsed fields are typos, even in documents that are passed through.
+	stream = ioutil.NopCloser(strings.NewReader("config_map:\n  name: koki\n  nmae: typo\n"))
+	_, err = ConvertEitherStreamsToKoki([]io.ReadCloser{stream})
+	if err == nil {
+		t.Error("expected an extraneous field error")
+	}
+}
+
+func TestConvertEitherStreamsToKube(t *testing.T) {
+	stream := ioutil.NopCloser(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kube
+---
+config_map:
+  name: koki
+  version: v1
+`))
+
+	objs, err := ConvertEitherStreamsToKube([]io.ReadCloser{stream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(objs))
+	}
+
+	for i, name := range []string{"kube", "koki"} {
+		configMap, ok := objs[i].(*v1.ConfigMap)
+		if !ok {
+			t.Errorf("object %d: expected a kube config map, got %T", i, objs[i])
+			continue
+		}
+		if configMap.Name != name {
+			t.Errorf("object %d: expected name (%s), got (%s)", i, name, configMap.Name)
+		}
+	}
+
+	// Documents that are neither are rejected, with their index.
+	stream = ioutil.NopCloser(strings.NewReader("config_map:\n  name: koki\n  version: v1\n---\nfoo: 1\nbar: 2\n"))
+	_, err = ConvertEitherStreamsToKube([]io.ReadCloser{stream})
+	if err == nil || !strings.Contains(err.Error(), "document 1") {
+		t.Errorf("expected an error for document 1, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/koki/json"
+	"github.com/koki/short/converter"
+	serrors "github.com/koki/structurederrors"
+)
+
+// ObjectID identifies a Kube object for diffing.
+type ObjectID struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+func (id ObjectID) String() string {
+	name := id.Name
+	if len(id.Namespace) > 0 {
+		name = id.Namespace + "/" + name
+	}
+
+	return fmt.Sprintf("%s %s (%s)", id.Kind, name, id.APIVersion)
+}
+
+// ChangeType is how an object differs between two manifest sets.
+type ChangeType string
+
+const (
+	ObjectAdded   ChangeType = "added"
+	ObjectRemoved ChangeType = "removed"
+	ObjectChanged ChangeType = "changed"
+)
+
+// ObjectDiff describes one object that differs between two manifest sets.
+// Old and New are the object in koki syntax (or kube syntax, if it has no koki equivalent).
+type ObjectDiff struct {
+	ID     ObjectID
+	Change ChangeType
+	Old    interface{}
+	New    interface{}
+
+	// Fields lists the changed fields of an ObjectChanged.
+	Fields []FieldDiff
+}
+
+// FieldDiff is a changed field. Old is nil if the field was added, and New is nil if it was removed.
+type FieldDiff struct {
+	// Path is the location of the field in koki syntax, e.g. $.pod.containers.0.image
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// DiffObjs compares two sets of Kube objects (see ConvertEitherStreamsToKube),
+// matching them by apiVersion, kind, namespace, and name.
+// Removed and changed objects are listed in their old order, followed by added objects in their new order.
+func DiffObjs(oldObjs, newObjs []interface{}) ([]ObjectDiff, error) {
+	oldIDs, oldByID, err := indexObjs(oldObjs)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "old objects")
+	}
+	newIDs, newByID, err := indexObjs(newObjs)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "new objects")
+	}
+
+	diffs := []ObjectDiff{}
+	for _, id := range oldIDs {
+		oldObj := oldByID[id]
+		newObj, ok := newByID[id]
+		if !ok {
+			diffs = append(diffs, ObjectDiff{ID: id, Change: ObjectRemoved, Old: oldObj.koki})
+			continue
+		}
+
+		fields := diffValues("$", oldObj.data, newObj.data)
+		if len(fields) > 0 {
+			diffs = append(diffs, ObjectDiff{
+				ID:     id,
+				Change: ObjectChanged,
+				Old:    oldObj.koki,
+				New:    newObj.koki,
+				Fields: fields,
+			})
+		}
+	}
+
+	for _, id := range newIDs {
+		if _, ok := oldByID[id]; !ok {
+			diffs = append(diffs, ObjectDiff{ID: id, Change: ObjectAdded, New: newByID[id].koki})
+		}
+	}
+
+	return diffs, nil
+}
+
+type diffObj struct {
+	// koki is the object in koki syntax if it has one.
+	koki interface{}
+
+	// data is koki as a generic JSON value.
+	data interface{}
+}
+
+func indexObjs(objs []interface{}) ([]ObjectID, map[ObjectID]diffObj, error) {
+	ids := []ObjectID{}
+	byID := map[ObjectID]diffObj{}
+	for i, obj := range objs {
+		id, err := objectIDForKubeObj(obj)
+		if err != nil {
+			return nil, nil, serrors.ContextualizeErrorf(err, "[%d]", i)
+		}
+		if _, ok := byID[id]; ok {
+			return nil, nil, serrors.InvalidValueErrorf(id.String(), "duplicate object")
+		}
+
+		kokiObj := obj
+		if runtimeObj, ok := obj.(runtime.Object); ok {
+			if converted, err := converter.DetectAndConvertFromKubeObj(runtimeObj); err == nil {
+				kokiObj = converted
+			}
+		}
+
+		b, err := json.Marshal(kokiObj)
+		if err != nil {
+			return nil, nil, serrors.InvalidValueContextErrorf(err, kokiObj, "couldn't serialize as json")
+		}
+		var data interface{}
+		err = json.Unmarshal(b, &data)
+		if err != nil {
+			return nil, nil, serrors.InvalidValueContextErrorf(err, string(b), "couldn't deserialize json")
+		}
+
+		ids = append(ids, id)
+		byID[id] = diffObj{koki: kokiObj, data: data}
+	}
+
+	return ids, byID, nil
+}
+
+func objectIDForKubeObj(obj interface{}) (ObjectID, error) {
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		return ObjectID{}, serrors.TypeErrorf(obj, "expected a kube object")
+	}
+	metaObj, ok := obj.(metav1.Object)
+	if !ok {
+		return ObjectID{}, serrors.TypeErrorf(obj, "expected a kube object with metadata")
+	}
+
+	gvk := runtimeObj.GetObjectKind().GroupVersionKind()
+	return ObjectID{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  metaObj.GetNamespace(),
+		Name:       metaObj.GetName(),
+	}, nil
+}
+
+// diffValues compares two generic JSON values. Lists are compared item by item.
+func diffValues(path string, oldVal, newVal interface{}) []FieldDiff {
+	switch oldVal := oldVal.(type) {
+	case map[string]interface{}:
+		if newVal, ok := newVal.(map[string]interface{}); ok {
+			return diffMaps(path, oldVal, newVal)
+		}
+	case []interface{}:
+		if newVal, ok := newVal.([]interface{}); ok {
+			return diffSlices(path, oldVal, newVal)
+		}
+	}
+
+	if reflect.DeepEqual(oldVal, newVal) {
+		return nil
+	}
+
+	return []FieldDiff{{Path: path, Old: oldVal, New: newVal}}
+}
+
+func diffMaps(path string, oldMap, newMap map[string]interface{}) []FieldDiff {
+	keys := []string{}
+	for key := range oldMap {
+		keys = append(keys, key)
+	}
+	for key := range newMap {
+		if _, ok := oldMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	diffs := []FieldDiff{}
+	for _, key := range keys {
+		diffs = append(diffs, diffValues(path+"."+key, oldMap[key], newMap[key])...)
+	}
+
+	return diffs
+}
+
+func diffSlices(path string, oldSlice, newSlice []interface{}) []FieldDiff {
+	diffs := []FieldDiff{}
+	for i := 0; i < len(oldSlice) || i < len(newSlice); i++ {
+		var oldItem, newItem interface{}
+		if i < len(oldSlice) {
+			oldItem = oldSlice[i]
+		}
+		if i < len(newSlice) {
+			newItem = newSlice[i]
+		}
+		diffs = append(diffs, diffValues(path+"."+strconv.Itoa(i), oldItem, newItem)...)
+	}
+
+	return diffs
+}
+
+// FormatFieldValue renders a field value from a FieldDiff on a single line.
+func FormatFieldValue(val interface{}) string {
+	if str, ok := val.(string); ok && !strings.ContainsAny(str, "\n") {
+		return str
+	}
+
+	b, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+
+	return string(b)
+}
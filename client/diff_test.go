@@ -0,0 +1,52 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestDiffObjs(t *testing.T) {
+	oldPod := kubeObj("Pod", "default", "web").(*v1.Pod)
+	oldPod.Labels = map[string]string{"app": "web"}
+	oldPod.Spec.Containers = []v1.Container{{Name: "nginx", Image: "nginx:1.12"}}
+
+	newPod := kubeObj("Pod", "default", "web").(*v1.Pod)
+	newPod.Labels = map[string]string{"app": "web", "tier": "front"}
+	newPod.Spec.Containers = []v1.Container{{Name: "nginx", Image: "nginx:1.13"}}
+
+	oldObjs := []interface{}{oldPod, kubeObj("Service", "default", "old"), kubeObj("Namespace", "", "same")}
+	newObjs := []interface{}{kubeObj("ConfigMap", "default", "cfg"), kubeObj("Namespace", "", "same"), newPod}
+
+	diffs, err := DiffObjs(oldObjs, newObjs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []string{}
+	for _, diff := range diffs {
+		changes = append(changes, string(diff.Change)+" "+diff.ID.String())
+	}
+	expectedChanges := []string{
+		"changed Pod default/web (v1)",
+		"removed Service default/old (v1)",
+		"added ConfigMap default/cfg (v1)",
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Fatalf("expected\n%v\ngot\n%v", expectedChanges, changes)
+	}
+
+	expectedFields := []FieldDiff{
+		{Path: "$.pod.containers.0.image", Old: "nginx:1.12", New: "nginx:1.13"},
+		{Path: "$.pod.labels.tier", New: "front"},
+	}
+	if !reflect.DeepEqual(diffs[0].Fields, expectedFields) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expectedFields, diffs[0].Fields)
+	}
+
+	_, err = DiffObjs([]interface{}{oldPod, newPod}, nil)
+	if err == nil {
+		t.Error("expected an error for duplicate objects")
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koki/json"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// FileResult holds the objects converted from a single input file, in document order.
+type FileResult struct {
+	Filename string
+	Objs     []interface{}
+
+	// Comments are the comments of the documents the objects were converted from.
+	Comments ObjComments
+}
+
+// SourceDocument is an input document that's already in the output format, so it's output
+// as it was written. (e.g. A koki module converted to koki keeps its imports and params.)
+type SourceDocument struct {
+	// Obj is the parsed document. It's used for JSON output, and for YAML output if there's no Source.
+	Obj map[string]interface{}
+
+	// Source is the document's YAML source. (See parser.SourceMap.)
+	Source []byte
+}
+
+// MarshalJSON marshals the parsed document.
+func (d *SourceDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Obj)
+}
+
+// ConvertKokiFiles converts each Koki file (or URL) to Kube objects.
+// Imports aren't evaluated--use the imports package for modules with imports.
+func ConvertKokiFiles(filenames []string) ([]FileResult, error) {
+	return convertFiles(filenames, ConvertKokiMaps)
+}
+
+// ConvertKubeFiles converts each Kube file (or URL) to Koki objects.
+func ConvertKubeFiles(filenames []string) ([]FileResult, error) {
+	return convertFiles(filenames, ConvertKubeMaps)
+}
+
+func convertFiles(filenames []string, convert func([]map[string]interface{}) ([]interface{}, error)) ([]FileResult, error) {
+	results := make([]FileResult, len(filenames))
+	for i, filename := range filenames {
+		docs, maps, err := parser.ReadSource(filename)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, filename)
+		}
+
+		// Convert one document at a time so errors can be traced back to their source.
+		objs := make([]interface{}, len(docs))
+		for j, doc := range docs {
+			converted, err := convert([]map[string]interface{}{doc})
+			if err != nil {
+				return nil, serrors.ContextualizeErrorf(parser.LocateError(err, maps.Document(j)), filename)
+			}
+			objs[j] = converted[0]
+		}
+
+		results[i] = FileResult{
+			Filename: filename,
+			Objs:     objs,
+		}
+	}
+
+	return results, nil
+}
+
+// ObjFilename names the file for a single Koki or Kube object:
+// <kind>-<namespace>-<name><ext>, or <kind>-<name><ext> if it has no namespace.
+// Names that could be paths (e.g. ../x) are rejected, so the file stays in its directory.
+func ObjFilename(obj interface{}, ext string) (string, error) {
+	key := keyForObj(obj)
+	if len(key.Kind) == 0 || len(key.Name) == 0 {
+		if doc, ok := obj.(*SourceDocument); ok {
+			obj = doc.Obj
+		}
+		return "", serrors.InvalidValueErrorf(obj, "couldn't determine kind and name to use as a filename")
+	}
+
+	parts := []string{key.Kind}
+	if len(key.Namespace) > 0 {
+		parts = append(parts, key.Namespace)
+	}
+	parts = append(parts, key.Name)
+	for _, part := range parts {
+		if strings.ContainsAny(part, `/\`) || strings.Contains(part, "..") {
+			return "", serrors.InvalidValueErrorf(part, "(%s) can't be used in a filename, because it could be a path", part)
+		}
+	}
+
+	return fmt.Sprintf("%s%s", strings.ToLower(strings.Join(parts, "-")), ext), nil
+}
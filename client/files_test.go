@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/koki/short/types"
+)
+
+func TestConvertKubeFiles(t *testing.T) {
+	filenames := []string{
+		"../testdata/config_maps/config_map.yaml",
+		"../testdata/network_policies/network_policy.yaml",
+	}
+	results, err := ConvertKubeFiles(filenames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(filenames) {
+		t.Fatalf("expected %d results, got %d", len(filenames), len(results))
+	}
+	for i, result := range results {
+		if result.Filename != filenames[i] {
+			t.Errorf("expected result for %s, got %s", filenames[i], result.Filename)
+		}
+		if len(result.Objs) != 1 {
+			t.Errorf("%s: expected 1 object, got %d", result.Filename, len(result.Objs))
+		}
+	}
+
+	if _, ok := results[1].Objs[0].(*types.NetworkPolicyWrapper); !ok {
+		t.Errorf("expected a network policy, got %#v", results[1].Objs[0])
+	}
+}
+
+func TestObjFilename(t *testing.T) {
+	for _, test := range []struct {
+		obj      interface{}
+		expected string
+	}{
+		{kubeObj("Pod", "default", "nginx"), "pod-default-nginx.yaml"},
+		{kubeObj("Namespace", "", "prod"), "namespace-prod.yaml"},
+		{&types.SecretWrapper{Secret: types.Secret{Name: "creds", Namespace: "a"}}, "secret-a-creds.yaml"},
+		{&SourceDocument{Obj: map[string]interface{}{"params": []interface{}{}, "service": map[string]interface{}{"name": "web"}}}, "service-web.yaml"},
+	} {
+		actual, err := ObjFilename(test.obj, ".yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+
+	_, err := ObjFilename(kubeObj("Pod", "default", ""), ".yaml")
+	if err == nil {
+		t.Error("expected an error for an object without a name")
+	}
+
+	_, err = ObjFilename(&SourceDocument{Obj: map[string]interface{}{"pod": map[string]interface{}{"name": "${name}"}}}, ".yaml")
+	if err == nil {
+		t.Error("expected an error for a module with a templated name")
+	}
+
+	// Names can't write outside of the output directory.
+	for _, obj := range []interface{}{
+		&SourceDocument{Obj: map[string]interface{}{"pod": map[string]interface{}{"name": "../../x"}}},
+		kubeObj("Pod", "..", "x"),
+		kubeObj("Pod", "default", `..\x`),
+		kubeObj("Pod", "default", "a/b"),
+	} {
+		name, err := ObjFilename(obj, ".yaml")
+		if err == nil || !strings.Contains(err.Error(), "could be a path") {
+			t.Errorf("expected an error for a name that could be a path, got (%s) %v", name, err)
+		}
+	}
+}
+
+func TestSourceDocument(t *testing.T) {
+	source := "# the web pod\nparams:\n- name: pod name\npod:\n  name: ${name}  # templated\n"
+	doc := &SourceDocument{
+		Obj:    map[string]interface{}{"params": []interface{}{"name: pod name"}, "pod": map[string]interface{}{"name": "${name}"}},
+		Source: []byte(strings.TrimSuffix(source, "\n")),
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteObjsToYamlStream([]interface{}{doc, doc}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := source + "---\n" + source; buf.String() != expected {
+		t.Errorf("expected the source of each document\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = WriteObjsToJSONStream([]interface{}{doc}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"name": "${name}"`) {
+		t.Errorf("expected the parsed document as json, got\n%s", buf.String())
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/golang/glog"
+	yaml2 "gopkg.in/yaml.v2"
+
+	"github.com/koki/short/parser"
+	"github.com/koki/short/template"
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+// moduleSectionOrder is the order of the sections of a formatted koki document.
+// The resource comes after them.
+var moduleSectionOrder = []string{"imports", "params"}
+
+// CanonicalKokiDocument formats a koki document canonically: its imports and params
+// come first, unchanged, and its resource is re-marshalled from the koki types, which
+// sorts keys and uses the shortest syntax for each field.
+//
+// source is the document's YAML (or JSON) source, which the imports and params are
+// read from so the order of their keys is kept. Without it, their keys are sorted too.
+//
+// Resources with template holes (${...}) often can't be parsed until they're filled,
+// so they're only re-marshalled if parsing keeps every hole. Otherwise only their
+// keys are sorted.
+//
+// The document's comments (if any) are kept, at the same paths in the formatted document.
+func CanonicalKokiDocument(obj map[string]interface{}, source []byte, comments *DocumentComments) ([]byte, error) {
+	format, err := DetectFormat(obj)
+	if err != nil {
+		return nil, err
+	}
+	if format != FormatKoki {
+		return nil, serrors.InvalidValueErrorf(obj, "expected a koki document, not kube-native (convert it with 'short -f' first)")
+	}
+
+	resource := map[string]interface{}{}
+	for key, val := range obj {
+		if !kokiModuleKeys[key] {
+			resource[key] = val
+		}
+	}
+
+	canonical, err := canonicalKokiResource(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := moduleSections(obj, source)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	for _, section := range sections {
+		b, err := yaml2.Marshal(yaml2.MapSlice{section})
+		if err != nil {
+			return nil, serrors.InvalidValueContextErrorf(err, section.Value, "couldn't serialize %s as yaml", section.Key)
+		}
+		buf.Write(b)
+	}
+
+	b, err := yaml.Marshal(canonical)
+	if err != nil {
+		return nil, serrors.InvalidValueErrorf(canonical, "couldn't serialize as yaml")
+	}
+	buf.Write(b)
+
+	if comments == nil || len(comments.Comments) == 0 {
+		return buf.Bytes(), nil
+	}
+
+	maps, err := parser.ReadSourceMaps("", buf.Bytes())
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "locating the fields of the formatted document")
+	}
+	mapper := &commentMapper{
+		rules: samePathRules,
+		from:  FormatKoki,
+		out:   maps[0],
+	}
+
+	return mapper.addComments(buf.Bytes(), comments.Comments), nil
+}
+
+// moduleSections finds the imports and params of a document, in moduleSectionOrder.
+// They're read from source if it's set, so their keys stay in the same order.
+func moduleSections(obj map[string]interface{}, source []byte) (yaml2.MapSlice, error) {
+	doc := yaml2.MapSlice{}
+	if source != nil {
+		err := yaml2.Unmarshal(source, &doc)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "reading imports and params")
+		}
+	} else {
+		for key, val := range obj {
+			doc = append(doc, yaml2.MapItem{Key: key, Value: val})
+		}
+	}
+
+	sections := yaml2.MapSlice{}
+	for _, key := range moduleSectionOrder {
+		for _, item := range doc {
+			if item.Key == key {
+				sections = append(sections, item)
+			}
+		}
+	}
+
+	return sections, nil
+}
+
+func canonicalKokiResource(resource map[string]interface{}) (interface{}, error) {
+	holes := template.Holes(resource)
+	kokiObj, err := ParseKokiMap(resource)
+	if err != nil {
+		if len(holes) == 0 {
+			return nil, err
+		}
+
+		glog.V(3).Infof("only sorting keys of a template that can't be parsed yet: %s", err.Error())
+		return resource, nil
+	}
+
+	if len(holes) == 0 {
+		return kokiObj, nil
+	}
+
+	unparsed, err := parser.UnparseKokiNativeObject(kokiObj)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(template.Holes(unparsed), holes) {
+		glog.V(3).Info("only sorting keys of a template whose holes don't survive parsing")
+		return resource, nil
+	}
+
+	return unparsed, nil
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/koki/short/parser"
+	"github.com/koki/short/yaml"
+)
+
+func TestCanonicalKokiDocument(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+
+		// expectedErr is empty if the document should format.
+		expectedErr string
+	}{
+		{
+			name: "sorts keys and uses the shortest syntax",
+			input: `
+service:
+  version: v1
+  port: "80"
+  name: web
+`,
+			expected: `
+service:
+  name: web
+  port: 80
+  version: v1
+`,
+		},
+		{
+			name: "keeps imports and params first",
+			input: `
+pod:
+  version: v1
+  name: web
+params:
+- tag: the image tag
+imports:
+- labels: ./labels.yaml
+`,
+			expected: `
+imports:
+- labels: ./labels.yaml
+params:
+- tag: the image tag
+pod:
+  name: web
+  version: v1
+`,
+		},
+		{
+			name: "keeps the order of the keys in imports and params",
+			input: `
+imports:
+- s1: ./side.yaml
+  params:
+    tag: v1
+params:
+- env: environment
+  default: dev
+- param: type
+  description: the type
+  default: web
+pod:
+  name: web
+`,
+			expected: `
+imports:
+- s1: ./side.yaml
+  params:
+    tag: v1
+params:
+- env: environment
+  default: dev
+- param: type
+  description: the type
+  default: web
+pod:
+  name: web
+`,
+		},
+		{
+			name: "keeps holes in parsed fields",
+			input: `
+pod:
+  version: v1
+  name: web
+  containers:
+  - name: web
+    image: nginx:${tag}
+    expose:
+    - "8080"
+`,
+			expected: `
+pod:
+  containers:
+  - expose:
+    - 8080
+    image: nginx:${tag}
+    name: web
+  name: web
+  version: v1
+`,
+		},
+		{
+			name: "only sorts keys of templates that can't be parsed yet",
+			input: `
+deployment:
+  version: apps/v1
+  replicas: ${replicas}
+  name: web
+`,
+			expected: `
+deployment:
+  name: web
+  replicas: ${replicas}
+  version: apps/v1
+`,
+		},
+		{
+			name: "keeps comments",
+			input: `
+# the web pod
+pod:
+  version: v1 # pinned
+  containers:
+  - image: nginx
+    # exposed by the web service
+    expose:
+    - "8080"
+  name: web
+params:
+# used by the image
+- tag: the image tag
+`,
+			expected: `
+# the web pod
+params:
+# used by the image
+- tag: the image tag
+pod:
+  containers:
+  # exposed by the web service
+  - expose:
+    - 8080
+    image: nginx
+  name: web
+  version: v1 # pinned
+`,
+		},
+		{
+			name: "rejects typos",
+			input: `
+pod:
+  name: web
+  nmae: typo
+`,
+			expectedErr: "extraneous fields",
+		},
+		{
+			name: "rejects kube-native documents",
+			input: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+`,
+			expectedErr: "expected a koki document",
+		},
+	}
+
+	for _, testCase := range testCases {
+		obj, comments := fmtTestDocument(t, testCase.input)
+		b, err := CanonicalKokiDocument(obj, []byte(testCase.input), comments)
+		if len(testCase.expectedErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), testCase.expectedErr) {
+				t.Errorf("%s: expected error (%s), got %v", testCase.name, testCase.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", testCase.name, err.Error())
+			continue
+		}
+
+		expected := strings.TrimPrefix(testCase.expected, "\n")
+		if string(b) != expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", testCase.name, expected, string(b))
+			continue
+		}
+
+		// Formatting is idempotent.
+		obj, comments = fmtTestDocument(t, string(b))
+		again, err := CanonicalKokiDocument(obj, b, comments)
+		if err != nil || string(again) != expected {
+			t.Errorf("%s: formatting again changed the document\n%s\n%v", testCase.name, string(again), err)
+		}
+	}
+}
+
+func fmtTestDocument(t *testing.T, input string) (map[string]interface{}, *DocumentComments) {
+	obj := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(input), &obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maps, err := parser.ReadSourceMaps("", []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return obj, NewDocumentComments(obj, maps[0].Comments)
+}
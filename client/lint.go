@@ -0,0 +1,495 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/koki/short/parser"
+	"github.com/koki/short/parser/expressions"
+	"github.com/koki/short/types"
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+/*
+
+Lint rules check typed koki objects (see parser.ParseKokiNativeObject) for risky settings.
+Kube-native documents are converted to koki first, so every rule works for both syntaxes.
+
+Findings are about koki fields. They're located in kube-native inputs with the same rules
+that carry comments between the syntaxes (see commentRules), so a finding on a field that
+the input doesn't have (e.g. a missing probe) points at the closest field that it does have.
+
+*/
+
+// LintDocument is a typed koki object to lint, and the document it was converted from.
+type LintDocument struct {
+	Filename string
+	// Document is the index of the document in the file, starting from 0.
+	Document int
+	// Input is the parsed input document, in either syntax.
+	Input map[string]interface{}
+	Obj   interface{}
+
+	// SourceMap locates the findings in the input, if its source was mapped.
+	SourceMap *parser.SourceMap
+}
+
+// LintFinding is a problem that a lint rule found in a document.
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Filename string `json:"file"`
+	Document int    `json:"document"`
+
+	// Path is the koki field that the finding is about (e.g. $.pod.containers.0.image).
+	Path string `json:"path"`
+
+	// Line and Column are the position of Path (or of its closest ancestor) in the file, if known.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	location := f.Filename
+	if f.Line > 0 {
+		location = parser.Position{Filename: f.Filename, Line: f.Line, Column: f.Column}.String()
+	}
+
+	return fmt.Sprintf("%s: document %d: %s: %s (%s)", location, f.Document, f.Path, f.Message, f.Rule)
+}
+
+// LintRule checks one kind of problem.
+type LintRule struct {
+	Name        string
+	Description string
+
+	// check finds the problems in doc. docs is every document being linted, including doc.
+	check func(doc LintDocument, docs []LintDocument) []lintProblem
+}
+
+type lintProblem struct {
+	path    string
+	message string
+}
+
+// LintRules are the built-in lint rules, in the order they're checked.
+var LintRules = []LintRule{
+	{
+		Name:        "image-tag",
+		Description: "images have a tag, and it isn't latest",
+		check:       lintContainers(lintImageTag),
+	},
+	{
+		Name:        "resources",
+		Description: "containers request (min) and limit (max) their cpu and mem",
+		check:       lintContainers(lintResources),
+	},
+	{
+		Name:        "probes",
+		Description: "containers of long-running pods have a liveness_probe and a readiness_probe",
+		check:       lintContainers(lintProbes),
+	},
+	{
+		Name:        "privileged",
+		Description: "containers aren't privileged",
+		check:       lintContainers(lintPrivileged),
+	},
+	{
+		Name:        "host-network",
+		Description: "pods don't use the node's network (host_mode: net)",
+		check:       lintHostNetwork,
+	},
+	{
+		Name:        "env-secrets",
+		Description: "env vars that look like secrets (e.g. *_PASSWORD) come from a secret, not a literal value",
+		check:       lintContainers(lintEnvSecrets),
+	},
+	{
+		Name:        "service-selector",
+		Description: "service selectors match the pods of a resource in the same input",
+		check:       lintServiceSelector,
+	},
+}
+
+// LintConfig enables and disables lint rules by name. Rules that it doesn't mention are enabled.
+type LintConfig struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// ReadLintConfig reads the lint config file at path, e.g.
+//
+//	rules:
+//	  probes: false
+func ReadLintConfig(path string) (*LintConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "reading lint config %s", path)
+	}
+
+	config := &LintConfig{}
+	err = yaml.Unmarshal(b, config)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, string(b), "parsing lint config %s", path)
+	}
+
+	for name := range config.Rules {
+		if findLintRule(name) == nil {
+			return nil, serrors.InvalidValueErrorf(name, "unknown lint rule %s in %s (expected one of %s)", name, path, strings.Join(lintRuleNames(), ", "))
+		}
+	}
+
+	return config, nil
+}
+
+// Enabled is true unless the config disables the rule. A nil config enables every rule.
+func (c *LintConfig) Enabled(rule string) bool {
+	if c == nil {
+		return true
+	}
+
+	enabled, ok := c.Rules[rule]
+	return !ok || enabled
+}
+
+func findLintRule(name string) *LintRule {
+	for i := range LintRules {
+		if LintRules[i].Name == name {
+			return &LintRules[i]
+		}
+	}
+
+	return nil
+}
+
+func lintRuleNames() []string {
+	names := make([]string, len(LintRules))
+	for i, rule := range LintRules {
+		names[i] = rule.Name
+	}
+
+	return names
+}
+
+// Lint checks each document with the rules that config enables. Findings are in document order,
+// and are located in their input files if they have been read. (See parser.ReadSource.)
+func Lint(docs []LintDocument, config *LintConfig) []LintFinding {
+	findings := []LintFinding{}
+	for _, doc := range docs {
+		for _, rule := range LintRules {
+			if !config.Enabled(rule.Name) {
+				continue
+			}
+
+			for _, problem := range rule.check(doc, docs) {
+				finding := LintFinding{
+					Rule:     rule.Name,
+					Filename: doc.Filename,
+					Document: doc.Document,
+					Path:     "$." + problem.path,
+					Message:  problem.message,
+				}
+				pos := locateLintPath(doc, problem.path)
+				finding.Line, finding.Column = pos.Line, pos.Column
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	return findings
+}
+
+// locateLintPath finds the position of a koki path in the document's input, or of its
+// closest ancestor that's in the input.
+func locateLintPath(doc LintDocument, path string) parser.Position {
+	sourceMap := doc.SourceMap
+	if sourceMap == nil {
+		return parser.Position{}
+	}
+
+	format, resource, err := resourceOf(doc.Input)
+	if err != nil {
+		return sourceMap.Root
+	}
+	if format == FormatKube {
+		mapper := &commentMapper{
+			rules: commentRules(resource, strings.Split(path, ".")[0]),
+			from:  FormatKoki,
+			out:   sourceMap,
+		}
+		path, _ = mapper.place(path)
+	}
+
+	return sourceMap.Lookup(path)
+}
+
+// lintPod is a pod, or the template for the pods of another resource.
+type lintPod struct {
+	// path is the koki path of the pod template's fields (e.g. deployment).
+	path      string
+	template  *types.PodTemplate
+	namespace string
+	// labels are the labels of the pods, if they're known.
+	labels map[string]string
+	// longRunning is false for pods that are expected to finish (e.g. for jobs).
+	longRunning bool
+}
+
+// lintPodsOf finds the pods that obj creates.
+func lintPodsOf(obj interface{}) []lintPod {
+	switch obj := obj.(type) {
+	case *types.PodWrapper:
+		pod := &obj.Pod
+		return []lintPod{{"pod", &pod.PodTemplate, pod.Namespace, pod.Labels, true}}
+	case *types.PodTemplateWrapper:
+		template := &obj.PodTemplate
+		return []lintPod{{"pod_template", &template.PodTemplate, template.Namespace, template.TemplateMetadata.Labels, true}}
+	case *types.DeploymentWrapper:
+		deployment := &obj.Deployment
+		return []lintPod{{"deployment", &deployment.PodTemplate, deployment.Namespace, lintTemplateLabels(deployment.TemplateMetadata, deployment.Selector), true}}
+	case *types.DaemonSetWrapper:
+		daemonSet := &obj.DaemonSet
+		return []lintPod{{"daemon_set", &daemonSet.PodTemplate, daemonSet.Namespace, lintTemplateLabels(daemonSet.TemplateMetadata, daemonSet.Selector), true}}
+	case *types.StatefulSetWrapper:
+		statefulSet := &obj.StatefulSet
+		return []lintPod{{"stateful_set", &statefulSet.PodTemplate, statefulSet.Namespace, lintTemplateLabels(statefulSet.TemplateMetadata, statefulSet.Selector), true}}
+	case *types.ReplicaSetWrapper:
+		replicaSet := &obj.ReplicaSet
+		return []lintPod{{"replica_set", &replicaSet.PodTemplate, replicaSet.Namespace, lintTemplateLabels(replicaSet.TemplateMetadata, replicaSet.Selector), true}}
+	case *types.ReplicationControllerWrapper:
+		rc := &obj.ReplicationController
+		labels := rc.Selector
+		if rc.TemplateMetadata != nil && len(rc.TemplateMetadata.Labels) > 0 {
+			labels = rc.TemplateMetadata.Labels
+		}
+		return []lintPod{{"replication_controller", &rc.PodTemplate, rc.Namespace, labels, true}}
+	case *types.JobWrapper:
+		job := &obj.Job
+		return []lintPod{{"job", &job.PodTemplate, job.Namespace, lintTemplateLabels(job.TemplateMetadata, job.Selector), false}}
+	case *types.CronJobWrapper:
+		cronJob := &obj.CronJob
+		return []lintPod{{"cron_job", &cronJob.PodTemplate, cronJob.Namespace, lintTemplateLabels(cronJob.JobTemplate.TemplateMetadata, cronJob.Selector), false}}
+	}
+
+	return nil
+}
+
+// lintTemplateLabels are the labels of a resource's pods. Like the converter, it uses the
+// selector's labels if the template doesn't have any.
+func lintTemplateLabels(meta *types.PodTemplateMeta, selector *types.RSSelector) map[string]string {
+	if meta != nil && len(meta.Labels) > 0 {
+		return meta.Labels
+	}
+	if selector == nil {
+		return nil
+	}
+	if len(selector.Shorthand) == 0 {
+		return selector.Labels
+	}
+
+	labelSelector, err := expressions.ParseLabelSelector(selector.Shorthand)
+	if err != nil || len(labelSelector.MatchExpressions) > 0 {
+		return nil
+	}
+
+	return labelSelector.MatchLabels
+}
+
+// lintContainerCheck checks one container of pod. path is the container's koki path.
+type lintContainerCheck func(pod lintPod, path string, container *types.Container) []lintProblem
+
+// lintContainers checks the containers and init containers of each pod in a document.
+func lintContainers(check lintContainerCheck) func(LintDocument, []LintDocument) []lintProblem {
+	return func(doc LintDocument, docs []LintDocument) []lintProblem {
+		problems := []lintProblem{}
+		for _, pod := range lintPodsOf(doc.Obj) {
+			for i := range pod.template.InitContainers {
+				problems = append(problems, check(pod, fmt.Sprintf("%s.init_containers.%d", pod.path, i), &pod.template.InitContainers[i])...)
+			}
+			for i := range pod.template.Containers {
+				problems = append(problems, check(pod, fmt.Sprintf("%s.containers.%d", pod.path, i), &pod.template.Containers[i])...)
+			}
+		}
+
+		return problems
+	}
+}
+
+func isInitContainerPath(path string) bool {
+	return strings.Contains(path, ".init_containers.")
+}
+
+func lintImageTag(pod lintPod, path string, container *types.Container) []lintProblem {
+	image := container.Image
+	if len(image) == 0 || strings.Contains(image, "@") {
+		// Pinned by digest.
+		return nil
+	}
+
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	switch {
+	case i < 0:
+		return []lintProblem{{path + ".image", fmt.Sprintf("image %s has no tag", image)}}
+	case name[i+1:] == "latest":
+		return []lintProblem{{path + ".image", fmt.Sprintf("image %s uses the latest tag", image)}}
+	}
+
+	return nil
+}
+
+func lintResources(pod lintPod, path string, container *types.Container) []lintProblem {
+	cpu, mem := types.CPU{}, types.Mem{}
+	if container.CPU != nil {
+		cpu = *container.CPU
+	}
+	if container.Mem != nil {
+		mem = *container.Mem
+	}
+
+	problems := []lintProblem{}
+	for _, resource := range []struct {
+		name     string
+		min, max string
+	}{
+		{"cpu", cpu.Min, cpu.Max},
+		{"mem", mem.Min, mem.Max},
+	} {
+		missing := []string{}
+		if len(resource.min) == 0 {
+			missing = append(missing, "request (min)")
+		}
+		if len(resource.max) == 0 {
+			missing = append(missing, "limit (max)")
+		}
+		if len(missing) > 0 {
+			problems = append(problems, lintProblem{
+				path:    path + "." + resource.name,
+				message: fmt.Sprintf("container %s has no %s %s", container.Name, resource.name, strings.Join(missing, " or ")),
+			})
+		}
+	}
+
+	return problems
+}
+
+func lintProbes(pod lintPod, path string, container *types.Container) []lintProblem {
+	if !pod.longRunning || isInitContainerPath(path) {
+		return nil
+	}
+
+	problems := []lintProblem{}
+	if container.LivenessProbe == nil {
+		problems = append(problems, lintProblem{path + ".liveness_probe", fmt.Sprintf("container %s has no liveness_probe", container.Name)})
+	}
+	if container.ReadinessProbe == nil {
+		problems = append(problems, lintProblem{path + ".readiness_probe", fmt.Sprintf("container %s has no readiness_probe", container.Name)})
+	}
+
+	return problems
+}
+
+func lintPrivileged(pod lintPod, path string, container *types.Container) []lintProblem {
+	if container.Privileged != nil && *container.Privileged {
+		return []lintProblem{{path + ".privileged", fmt.Sprintf("container %s is privileged", container.Name)}}
+	}
+
+	return nil
+}
+
+func lintHostNetwork(doc LintDocument, docs []LintDocument) []lintProblem {
+	problems := []lintProblem{}
+	for _, pod := range lintPodsOf(doc.Obj) {
+		for i, mode := range pod.template.HostMode {
+			if mode == types.HostModeNet {
+				problems = append(problems, lintProblem{fmt.Sprintf("%s.host_mode.%d", pod.path, i), "pods use the node's network"})
+			}
+		}
+	}
+
+	return problems
+}
+
+// secretEnvKeyRegexp matches the names of env vars that usually hold secrets.
+var secretEnvKeyRegexp = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private_?key|credential)`)
+
+func lintEnvSecrets(pod lintPod, path string, container *types.Container) []lintProblem {
+	problems := []lintProblem{}
+	for i, env := range container.Env {
+		if env.Type != types.EnvValEnvType || env.Val == nil || len(env.Val.Val) == 0 {
+			continue
+		}
+		if secretEnvKeyRegexp.MatchString(env.Val.Key) {
+			problems = append(problems, lintProblem{
+				path:    fmt.Sprintf("%s.env.%d", path, i),
+				message: fmt.Sprintf("env var %s has a literal value (use 'from: secret:<name>:<key>' instead)", env.Val.Key),
+			})
+		}
+	}
+
+	return problems
+}
+
+func lintServiceSelector(doc LintDocument, docs []LintDocument) []lintProblem {
+	wrapper, ok := doc.Obj.(*types.ServiceWrapper)
+	if !ok || len(wrapper.Service.Selector) == 0 {
+		return nil
+	}
+	service := wrapper.Service
+
+	for _, other := range docs {
+		for _, pod := range lintPodsOf(other.Obj) {
+			if sameNamespace(pod.namespace, service.Namespace) && labelsMatch(service.Selector, pod.labels) {
+				return nil
+			}
+		}
+	}
+
+	return []lintProblem{{"service.selector", fmt.Sprintf("selector %s doesn't match the pods of any resource in the input", formatLabels(service.Selector))}}
+}
+
+// sameNamespace treats an unset namespace as the default namespace.
+func sameNamespace(a, b string) bool {
+	if len(a) == 0 {
+		a = "default"
+	}
+	if len(b) == 0 {
+		b = "default"
+	}
+
+	return a == b
+}
+
+func labelsMatch(selector, labels map[string]string) bool {
+	for key, val := range selector {
+		if podVal, ok := labels[key]; !ok || podVal != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := []string{}
+	for key, val := range labels {
+		pairs = append(pairs, key+"="+val)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// LintRulesHelp describes each lint rule, one per line.
+func LintRulesHelp() string {
+	lines := make([]string, len(LintRules))
+	for i, rule := range LintRules {
+		lines[i] = fmt.Sprintf("  %-18s %s", rule.Name, rule.Description)
+	}
+
+	return strings.Join(lines, "\n")
+}
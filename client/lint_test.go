@@ -0,0 +1,201 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koki/short/parser"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		config *LintConfig
+
+		// expected findings are written as "rule path line".
+		expected []string
+	}{
+		{
+			name: "koki pod",
+			input: `pod:
+  version: v1
+  name: web
+  labels:
+    app: web
+  host_mode:
+  - net
+  containers:
+  - name: web
+    image: nginx
+    privileged: true
+    env:
+    - DB_PASSWORD=hunter2
+    - LOG_LEVEL=debug
+    - from: secret:db:token
+      key: API_TOKEN
+    cpu:
+      min: 100m
+`,
+			expected: []string{
+				"image-tag $.pod.containers.0.image 10",
+				"resources $.pod.containers.0.cpu 17",
+				"resources $.pod.containers.0.mem 9",
+				"probes $.pod.containers.0.liveness_probe 9",
+				"probes $.pod.containers.0.readiness_probe 9",
+				"privileged $.pod.containers.0.privileged 11",
+				"host-network $.pod.host_mode.0 7",
+				"env-secrets $.pod.containers.0.env.0 13",
+			},
+		},
+		{
+			name: "kube-native pod, with rules disabled",
+			input: `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: web
+    image: nginx:latest
+    securityContext:
+      privileged: true
+`,
+			config: &LintConfig{Rules: map[string]bool{"resources": false, "probes": false, "image-tag": true}},
+			expected: []string{
+				"image-tag $.pod.containers.0.image 8",
+				"privileged $.pod.containers.0.privileged 10",
+			},
+		},
+		{
+			name: "well-configured job",
+			input: `job:
+  version: batch/v1
+  name: migrate
+  containers:
+  - name: migrate
+    image: example/migrate@sha256:0123
+    cpu:
+      min: 100m
+      max: 200m
+    mem:
+      min: 64Mi
+      max: 128Mi
+`,
+			expected: []string{},
+		},
+		{
+			name: "services match pods in other documents",
+			input: `service:
+  version: v1
+  name: web
+  port: 80:8080
+  selector:
+    app: web
+---
+service:
+  version: v1
+  name: api
+  namespace: prod
+  port: 80:8080
+  selector:
+    app: web
+---
+service:
+  version: v1
+  name: web-default
+  namespace: default
+  port: 80:8080
+  selector:
+    app: web
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: frontend
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.13
+`,
+			config: &LintConfig{Rules: map[string]bool{"resources": false, "probes": false}},
+			expected: []string{
+				"service-selector $.service.selector 13",
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		filename := fmt.Sprintf("lint%d.yaml", i)
+		docs := lintTestDocuments(t, filename, testCase.input)
+
+		actual := []string{}
+		for _, finding := range Lint(docs, testCase.config) {
+			actual = append(actual, fmt.Sprintf("%s %s %d", finding.Rule, finding.Path, finding.Line))
+		}
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", testCase.name, strings.Join(testCase.expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+}
+
+func lintTestDocuments(t *testing.T, filename, input string) []LintDocument {
+	objs, maps, err := parser.ParseSource(filename, []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kokiObjs, err := ConvertEitherMapsToKoki(objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := make([]LintDocument, len(objs))
+	for i := range objs {
+		docs[i] = LintDocument{Filename: filename, Document: i, Input: objs[i], Obj: kokiObjs[i], SourceMap: maps.Document(i)}
+	}
+
+	return docs
+}
+
+func TestReadLintConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lint.yaml")
+	err = ioutil.WriteFile(path, []byte("rules:\n  probes: false\n  image-tag: true\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ReadLintConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Enabled("probes") || !config.Enabled("image-tag") || !config.Enabled("resources") {
+		t.Errorf("unexpected config %#v", config)
+	}
+
+	err = ioutil.WriteFile(path, []byte("rules:\n  probe: false\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadLintConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "unknown lint rule probe") {
+		t.Errorf("expected an unknown rule error, got %v", err)
+	}
+}
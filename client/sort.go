@@ -0,0 +1,204 @@
+package client
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/koki/short/converter"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// SortOrder is the order in which converted objects are output.
+type SortOrder string
+
+const (
+	// SortNone keeps objects in input order.
+	SortNone SortOrder = ""
+	// SortByKind orders objects by kind, then namespace, then name.
+	SortByKind SortOrder = "kind"
+	// SortForInstall orders objects so that dependencies are created first.
+	// (Namespaces, then CRDs, RBAC, config, and finally workloads.)
+	SortForInstall SortOrder = "install"
+)
+
+// installOrder lists kube kinds in the order they should be created.
+// Kinds that aren't listed are installed last.
+var installOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PriorityClass",
+	"ServiceAccount",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"NetworkPolicy",
+	"PodPreset",
+	"Service",
+	"Endpoints",
+	"PodTemplate",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"DaemonSet",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"HorizontalPodAutoscaler",
+	"PodDisruptionBudget",
+	"Ingress",
+	"APIService",
+	"InitializerConfiguration",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+var installRank = func() map[string]int {
+	ranks := map[string]int{}
+	for i, kind := range installOrder {
+		ranks[kind] = i
+	}
+	return ranks
+}()
+
+type objectKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// SortObjs returns Koki or Kube objects in the given order.
+// Objects that compare equal keep their input order.
+func SortObjs(objs []interface{}, order SortOrder) ([]interface{}, error) {
+	var less func(a, b objectKey) bool
+	switch order {
+	case SortNone:
+		return objs, nil
+	case SortByKind:
+		less = lessByKind
+	case SortForInstall:
+		less = lessForInstall
+	default:
+		return nil, serrors.InvalidValueErrorf(order, "unsupported sort order (expected %s or %s)", SortByKind, SortForInstall)
+	}
+
+	keys := make([]objectKey, len(objs))
+	for i, obj := range objs {
+		keys[i] = keyForObj(obj)
+	}
+
+	indices := make([]int, len(objs))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return less(keys[indices[i]], keys[indices[j]])
+	})
+
+	sorted := make([]interface{}, len(objs))
+	for i, index := range indices {
+		sorted[i] = objs[index]
+	}
+
+	return sorted, nil
+}
+
+func lessByKind(a, b objectKey) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+func lessForInstall(a, b objectKey) bool {
+	return rankForInstall(a.Kind) < rankForInstall(b.Kind)
+}
+
+func rankForInstall(kind string) int {
+	if rank, ok := installRank[kind]; ok {
+		return rank
+	}
+
+	return len(installOrder)
+}
+
+// keyForObj identifies an object by its Kube kind, namespace, and name.
+// Koki objects are converted to Kube first so both formats share one ordering.
+func keyForObj(obj interface{}) objectKey {
+	if doc, ok := obj.(*SourceDocument); ok {
+		return keyForSource(doc)
+	}
+
+	if kubeObj, err := converter.DetectAndConvertFromKokiObj(obj); err == nil {
+		obj = kubeObj
+	}
+
+	key := objectKey{}
+	if runtimeObj, ok := obj.(runtime.Object); ok {
+		key.Kind = runtimeObj.GetObjectKind().GroupVersionKind().Kind
+	}
+
+	// Not every object has metadata (e.g. a bare volume).
+	if metaObj, ok := obj.(metav1.Object); ok {
+		key.Namespace = metaObj.GetNamespace()
+		key.Name = metaObj.GetName()
+	}
+
+	return key
+}
+
+// keyForSource identifies a document that's output as written. A koki module isn't
+// evaluated, so a templated name or namespace is unknown (e.g. for ObjFilename).
+func keyForSource(doc *SourceDocument) objectKey {
+	key := literalKeyForSource(doc)
+	if strings.Contains(key.Namespace, "${") {
+		key.Namespace = ""
+	}
+	if strings.Contains(key.Name, "${") {
+		key.Name = ""
+	}
+
+	return key
+}
+
+// literalKeyForSource parses the document if it can. A koki module may not parse before
+// it's evaluated (e.g. a number is a template), so its key falls back to its literal kind and name.
+func literalKeyForSource(doc *SourceDocument) objectKey {
+	format, kind, err := resourceOf(doc.Obj)
+	if err != nil {
+		return objectKey{}
+	}
+
+	fields := doc.Obj
+	if format == FormatKube {
+		if obj, err := ParseKubeMap(doc.Obj); err == nil {
+			return keyForObj(obj)
+		}
+		fields, _ = doc.Obj["metadata"].(map[string]interface{})
+	} else {
+		if obj, err := parser.ParseKokiNativeObject(map[string]interface{}{kind: doc.Obj[kind]}); err == nil {
+			return keyForObj(obj)
+		}
+		fields, _ = doc.Obj[kind].(map[string]interface{})
+	}
+
+	namespace, _ := fields["namespace"].(string)
+	name, _ := fields["name"].(string)
+	return objectKey{Kind: kind, Namespace: namespace, Name: name}
+}
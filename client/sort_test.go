@@ -0,0 +1,76 @@
+package client
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koki/short/types"
+)
+
+func kubeObj(kind, namespace, name string) interface{} {
+	meta := metav1.ObjectMeta{Namespace: namespace, Name: name}
+	typeMeta := metav1.TypeMeta{APIVersion: "v1", Kind: kind}
+	switch kind {
+	case "ConfigMap":
+		return &v1.ConfigMap{TypeMeta: typeMeta, ObjectMeta: meta}
+	case "Namespace":
+		return &v1.Namespace{TypeMeta: typeMeta, ObjectMeta: meta}
+	case "Pod":
+		return &v1.Pod{TypeMeta: typeMeta, ObjectMeta: meta}
+	case "Secret":
+		return &v1.Secret{TypeMeta: typeMeta, ObjectMeta: meta}
+	case "Service":
+		return &v1.Service{TypeMeta: typeMeta, ObjectMeta: meta}
+	}
+	panic(kind)
+}
+
+func sortedNames(t *testing.T, objs []interface{}, order SortOrder) []string {
+	sorted, err := SortObjs(objs, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(sorted))
+	for i, obj := range sorted {
+		names[i] = keyForObj(obj).Name
+	}
+	return names
+}
+
+func expectNames(t *testing.T, order SortOrder, actual []string, expected ...string) {
+	if len(actual) != len(expected) {
+		t.Fatalf("%s: expected %v, got %v", order, expected, actual)
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Fatalf("%s: expected %v, got %v", order, expected, actual)
+		}
+	}
+}
+
+func TestSortObjs(t *testing.T) {
+	objs := []interface{}{
+		kubeObj("Pod", "b", "pod-b"),
+		kubeObj("Service", "a", "svc"),
+		kubeObj("Pod", "a", "pod-a2"),
+		kubeObj("Pod", "a", "pod-a1"),
+		kubeObj("ConfigMap", "a", "config"),
+		&types.SecretWrapper{Secret: types.Secret{Name: "secret", Namespace: "a"}},
+		kubeObj("Namespace", "", "ns"),
+	}
+
+	expectNames(t, SortNone, sortedNames(t, objs, SortNone),
+		"pod-b", "svc", "pod-a2", "pod-a1", "config", "secret", "ns")
+	expectNames(t, SortByKind, sortedNames(t, objs, SortByKind),
+		"config", "ns", "pod-a1", "pod-a2", "pod-b", "secret", "svc")
+	expectNames(t, SortForInstall, sortedNames(t, objs, SortForInstall),
+		"ns", "secret", "config", "svc", "pod-b", "pod-a2", "pod-a1")
+
+	_, err := SortObjs(objs, SortOrder("name"))
+	if err == nil {
+		t.Error("expected an error for an unsupported sort order")
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/koki/json/jsonutil"
+	"github.com/koki/short/parser"
+)
+
+// DocumentError is a problem with one document in an input file.
+type DocumentError struct {
+	Filename string `json:"file"`
+
+	// Document is the index of the document in the file, starting from 0.
+	// It's -1 if the file itself couldn't be read or parsed.
+	Document int `json:"document"`
+
+	// Path is the location of the problem in the document (e.g. $.pod.containers.0.imagee), if known.
+	Path string `json:"path,omitempty"`
+
+	// Line and Column are the position of Path (or of the document) in the file, if known.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (e DocumentError) Error() string {
+	location, message := e.Filename, e.Message
+	if e.Line > 0 {
+		location = parser.Position{Filename: e.Filename, Line: e.Line, Column: e.Column}.String()
+		// Located errors start with their position already.
+		message = strings.TrimPrefix(message, location+": ")
+	}
+	if e.Document >= 0 {
+		location = fmt.Sprintf("%s: document %d", location, e.Document)
+	}
+	// The path is usually in the message already, unless it's an extraneous field.
+	if len(e.Path) > 0 && !strings.Contains(message, e.Path) {
+		location = fmt.Sprintf("%s: %s", location, e.Path)
+	}
+
+	return fmt.Sprintf("%s: %s", location, message)
+}
+
+// NewDocumentErrors describes err for the given document.
+// Each extraneous field is reported as a separate error with its own path. Other errors
+// have the first path they mention in the document (e.g. $.deployment.replicas), if any.
+// Errors are located with the document's sourceMap (or nil, if it isn't mapped), and
+// errors that have been located already keep their positions. (See parser.LocateError.)
+func NewDocumentErrors(filename string, document int, sourceMap *parser.SourceMap, err error) []DocumentError {
+	errs := newDocumentErrors(filename, document, err)
+
+	for i := range errs {
+		var pos parser.Position
+		switch {
+		case len(errs[i].Path) == 0:
+			// The error may have been located already, e.g. a syntax error.
+			pos = firstLocationIn(err, filename).Position
+			if pos.Line == 0 && sourceMap != nil {
+				pos = sourceMap.Root
+			}
+		case sourceMap != nil:
+			pos = sourceMap.Lookup(errs[i].Path)
+		}
+		errs[i].Line, errs[i].Column = pos.Line, pos.Column
+	}
+
+	return errs
+}
+
+// firstLocationIn finds the first location in filename that's mentioned in err.
+// If err hasn't been located, that's its first path.
+func firstLocationIn(err error, filename string) parser.Location {
+	for _, location := range parser.Locations(err) {
+		if location.Position.Line == 0 || location.Position.Filename == filename {
+			return location
+		}
+	}
+
+	return parser.Location{}
+}
+
+func newDocumentErrors(filename string, document int, err error) []DocumentError {
+	base := parser.BaseError(err)
+
+	if extraneousErr, ok := base.(*jsonutil.ExtraneousFieldsError); ok && len(extraneousErr.Paths) > 0 {
+		errs := make([]DocumentError, len(extraneousErr.Paths))
+		for i, path := range extraneousErr.Paths {
+			errs[i] = DocumentError{
+				Filename: filename,
+				Document: document,
+				Path:     "$." + strings.Join(path, "."),
+				Message:  "extraneous field (typo?)",
+			}
+		}
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Path < errs[j].Path
+		})
+		return errs
+	}
+
+	return []DocumentError{
+		{
+			Filename: filename,
+			Document: document,
+			Path:     firstLocationIn(err, filename).Path,
+			Message:  err.Error(),
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koki/json/jsonutil"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+func TestNewDocumentErrors(t *testing.T) {
+	extraneous := &jsonutil.ExtraneousFieldsError{
+		Paths: [][]string{
+			{"pod", "foo"},
+			{"pod", "containers", "0", "imagee"},
+		},
+	}
+
+	expected := []DocumentError{
+		{Filename: "a.yaml", Document: 2, Path: "$.pod.containers.0.imagee", Message: "extraneous field (typo?)"},
+		{Filename: "a.yaml", Document: 2, Path: "$.pod.foo", Message: "extraneous field (typo?)"},
+	}
+	for _, err := range []error{extraneous, serrors.ContextualizeErrorf(extraneous, "converting")} {
+		actual := NewDocumentErrors("a.yaml", 2, nil, err)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected\n%#v\ngot\n%#v", expected, actual)
+		}
+	}
+
+	actual := NewDocumentErrors("a.yaml", -1, nil, fmt.Errorf("bad yaml"))
+	if len(actual) != 1 || actual[0].Error() != "a.yaml: bad yaml" {
+		t.Errorf("unexpected errors %#v", actual)
+	}
+
+	// Other errors have the first path they mention.
+	decodeErr := serrors.ContextualizeErrorf(fmt.Errorf("expected a number"), "$.deployment.replicas")
+	for _, err := range []error{
+		decodeErr,
+		&parser.PathError{Path: []string{"deployment", "replicas"}, Err: fmt.Errorf("expected a number")},
+	} {
+		actual = NewDocumentErrors("a.yaml", 0, nil, serrors.ContextualizeErrorf(err, "converting"))
+		if len(actual) != 1 || actual[0].Path != "$.deployment.replicas" {
+			t.Errorf("expected the path of %s, got %#v", err.Error(), actual)
+		}
+	}
+
+	// Located errors have the position of their path.
+	_, maps, err := parser.ParseSource("b.yaml", []byte("deployment:\n  name: web\n  replicas: many\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = NewDocumentErrors("b.yaml", 0, maps.Document(0), parser.LocateError(decodeErr, maps.Document(0)))
+	if len(actual) != 1 || actual[0].Path != "$.deployment.replicas" || actual[0].Line != 3 || actual[0].Column != 3 {
+		t.Errorf("expected the located path, got %#v", actual)
+	}
+
+	// Syntax errors are located when they're parsed.
+	_, _, err = parser.ParseSource("c.yaml", []byte("a: 1\nb: [\n"))
+	actual = NewDocumentErrors("c.yaml", -1, nil, err)
+	if len(actual) != 1 || actual[0].Line < 2 {
+		t.Errorf("expected the position of the syntax error, got %#v", actual)
+	}
+	if strings.Count(actual[0].Error(), "c.yaml:") != 1 {
+		t.Errorf("expected the position once, got %s", actual[0].Error())
+	}
+
+	if expected[0].Error() != "a.yaml: document 2: $.pod.containers.0.imagee: extraneous field (typo?)" {
+		t.Errorf("unexpected message %s", expected[0].Error())
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+
+	"github.com/koki/short/client"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+var (
+	diffCmd = &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Compare two sets of manifests, in either koki or kube-native syntax",
+		Long: `Diff compares the objects in two sets of manifests.
+
+Each side can be a file, a directory, a url, or '-' for stdin, and can use koki or kube-native syntax.
+Objects are matched by apiVersion, kind, namespace, and name. Changed fields are shown in koki syntax.
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			err := diff(c, args)
+			if err != nil {
+				return errors.New(serrors.PrettyError(err))
+			}
+
+			return nil
+		},
+		SilenceUsage: true,
+		Example: `
+  # Compare two versions of a manifest
+  short diff old/app.short.yaml new/app.short.yaml
+
+  # Compare a directory of koki manifests with what's in the cluster
+  kubectl get deploy,svc -o yaml | short diff manifests/ -
+
+  # Fail a CI job if the manifests differ
+  short diff -R base/ generated/ --exit-code
+`,
+	}
+
+	// diffExitCode denotes that diff should exit non-zero if the manifests differ
+	diffExitCode bool
+)
+
+func init() {
+	diffCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "process the directories recursively")
+	diffCmd.Flags().StringSliceVarP(&includeGlobs, "include", "", nil, "only read files from directories if they match one of these globs")
+	diffCmd.Flags().StringSliceVarP(&excludeGlobs, "exclude", "", nil, "skip files in directories that match any of these globs")
+	diffCmd.Flags().BoolVarP(&diffExitCode, "exit-code", "", false, "exit with status 1 if there are differences")
+	diffCmd.Flags().BoolVarP(&verboseErrors, "verbose-errors", "", false, "include more information in errors")
+
+	RootCmd.AddCommand(diffCmd)
+}
+
+func diff(c *cobra.Command, args []string) error {
+	serrors.SetVerboseErrors(verboseErrors)
+	glog.V(3).Infof("validating command %q", args)
+
+	if len(args) != 2 {
+		return serrors.UsageErrorf(c.CommandPath(), "expected two inputs to compare, got %q", args)
+	}
+	if args[0] == "-" && args[1] == "-" {
+		return serrors.UsageErrorf(c.CommandPath(), "only one input can be read from stdin")
+	}
+
+	oldObjs, err := loadKubeObjs(args[0])
+	if err != nil {
+		return serrors.ContextualizeErrorf(err, "reading %s", args[0])
+	}
+
+	newObjs, err := loadKubeObjs(args[1])
+	if err != nil {
+		return serrors.ContextualizeErrorf(err, "reading %s", args[1])
+	}
+
+	diffs, err := client.DiffObjs(oldObjs, newObjs)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	err = writeDiffs(diffs, buf)
+	if err != nil {
+		return err
+	}
+	fmt.Print(buf.String())
+
+	if diffExitCode && len(diffs) > 0 {
+		return fmt.Errorf("%d objects differ", len(diffs))
+	}
+
+	return nil
+}
+
+// loadKubeObjs reads a file, directory, url, or stdin ("-") in either syntax as Kube objects.
+func loadKubeObjs(input string) ([]interface{}, error) {
+	if input == "-" {
+		return client.ConvertEitherStreamsToKube([]io.ReadCloser{os.Stdin})
+	}
+
+	streams, err := parser.OpenStreamsFromPaths([]string{input}, parser.PathOptions{
+		Recursive: recursive,
+		Include:   includeGlobs,
+		Exclude:   excludeGlobs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return client.ConvertEitherStreamsToKube(streams)
+}
+
+// writeDiffs prints added and removed objects in full, and changed objects field by field:
+//
+//	~ Pod default/nginx (v1)
+//	    $.pod.containers.0.image: nginx:1.12 -> nginx:1.13
+//	  + $.pod.labels.tier: web
+//	  - $.pod.annotations.owner: me
+func writeDiffs(diffs []client.ObjectDiff, w io.Writer) error {
+	for _, objDiff := range diffs {
+		switch objDiff.Change {
+		case client.ObjectAdded:
+			fmt.Fprintf(w, "+ %s\n", objDiff.ID)
+			err := writePrefixedYaml(objDiff.New, "  + ", w)
+			if err != nil {
+				return err
+			}
+		case client.ObjectRemoved:
+			fmt.Fprintf(w, "- %s\n", objDiff.ID)
+			err := writePrefixedYaml(objDiff.Old, "  - ", w)
+			if err != nil {
+				return err
+			}
+		case client.ObjectChanged:
+			fmt.Fprintf(w, "~ %s\n", objDiff.ID)
+			for _, field := range objDiff.Fields {
+				switch {
+				case field.Old == nil:
+					fmt.Fprintf(w, "  + %s: %s\n", field.Path, client.FormatFieldValue(field.New))
+				case field.New == nil:
+					fmt.Fprintf(w, "  - %s: %s\n", field.Path, client.FormatFieldValue(field.Old))
+				default:
+					fmt.Fprintf(w, "    %s: %s -> %s\n", field.Path, client.FormatFieldValue(field.Old), client.FormatFieldValue(field.New))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func writePrefixedYaml(obj interface{}, prefix string, w io.Writer) error {
+	buf := &bytes.Buffer{}
+	err := client.WriteObjsToYamlStream([]interface{}{obj}, buf)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", prefix, line)
+	}
+
+	return nil
+}
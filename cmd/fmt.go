@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+
+	"github.com/koki/short/client"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+var (
+	fmtCmd = &cobra.Command{
+		Use:   "fmt",
+		Short: "Format koki manifests canonically",
+		Long: `Fmt rewrites koki manifests in a canonical style: keys are sorted, and each field uses its shortest syntax.
+
+Imports and params come first in each document, with their contents unchanged. Template holes (${...}) and
+comments are kept too. Resources whose holes can't be parsed until they're filled only have their keys sorted.
+
+Kube-native documents are left as they are, and listed on stderr.
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			err := formatManifests(c, args)
+			if err != nil {
+				return errors.New(serrors.PrettyError(err))
+			}
+
+			return nil
+		},
+		SilenceUsage: true,
+		Example: `
+  # Print a formatted manifest
+  short fmt -f app.short.yaml
+
+  # Format every manifest under a directory tree in place
+  short fmt -R -f manifests/ -w
+
+  # List unformatted manifests, and fail a CI job if there are any
+  short fmt -R -f manifests/ --check
+`,
+	}
+
+	// fmtCheck denotes that unformatted files should be listed instead of formatted
+	fmtCheck bool
+	// fmtWrite denotes that unformatted files should be overwritten with their formatted contents
+	fmtWrite bool
+)
+
+func init() {
+	fmtCmd.Flags().StringSliceVarP(&filenames, "filenames", "f", nil, "path or url to input files to format")
+	fmtCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "process the directories in -f recursively")
+	fmtCmd.Flags().StringSliceVarP(&includeGlobs, "include", "", nil, "only read files from directories if they match one of these globs")
+	fmtCmd.Flags().StringSliceVarP(&excludeGlobs, "exclude", "", nil, "skip files in directories that match any of these globs")
+	fmtCmd.Flags().BoolVarP(&fmtCheck, "check", "", false, "list files that aren't formatted, and exit non-zero if there are any")
+	fmtCmd.Flags().BoolVarP(&fmtWrite, "write", "w", false, "overwrite files that aren't formatted with their formatted contents")
+	fmtCmd.Flags().BoolVarP(&verboseErrors, "verbose-errors", "", false, "include more information in errors")
+
+	RootCmd.AddCommand(fmtCmd)
+}
+
+func formatManifests(c *cobra.Command, args []string) error {
+	serrors.SetVerboseErrors(verboseErrors)
+	glog.V(3).Infof("formatting command %q", args)
+
+	useStdin := false
+	if len(args) == 1 && args[0] == "-" && len(filenames) == 0 {
+		useStdin = true
+	} else if len(args) > 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected values %q", args)
+	}
+
+	if !useStdin && len(filenames) == 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "expected -f or '-' for stdin")
+	}
+
+	if fmtCheck && fmtWrite {
+		return serrors.UsageErrorf(c.CommandPath(), "--check can't be used with --write")
+	}
+
+	if useStdin && fmtWrite {
+		return serrors.UsageErrorf(c.CommandPath(), "--write requires input files")
+	}
+
+	if useStdin {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "reading stdin")
+		}
+
+		_, err = formatInput("stdin", data)
+		return err
+	}
+
+	paths, err := parser.ExpandPaths(filenames, parser.PathOptions{
+		Recursive: recursive,
+		Include:   includeGlobs,
+		Exclude:   excludeGlobs,
+	})
+	if err != nil {
+		return err
+	}
+
+	unformatted := 0
+	for i, path := range paths {
+		if fmtWrite && parser.IsURL(path) {
+			return serrors.InvalidValueErrorf(path, "--write can't overwrite a url")
+		}
+
+		data, err := readInput(path)
+		if err != nil {
+			return err
+		}
+
+		// Printed files are separated like the documents in them.
+		if i > 0 && !fmtCheck && !fmtWrite {
+			fmt.Print("---\n")
+		}
+
+		changed, err := formatInput(path, data)
+		if err != nil {
+			return err
+		}
+		if changed {
+			unformatted++
+		}
+	}
+
+	if fmtCheck && unformatted > 0 {
+		return fmt.Errorf("%d of %d files aren't formatted (run 'short fmt -w' to format them)", unformatted, len(paths))
+	}
+
+	return nil
+}
+
+func readInput(path string) ([]byte, error) {
+	stream, err := parser.OpenStream(path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "reading %s", path)
+	}
+
+	return data, nil
+}
+
+// formatInput formats the documents in data, then prints them, lists the
+// input (with --check), or overwrites it (with --write).
+// It returns true if the input wasn't already formatted.
+func formatInput(filename string, data []byte) (bool, error) {
+	formatted, err := formatDocuments(filename, data)
+	if err != nil {
+		return false, err
+	}
+
+	changed := !bytes.Equal(formatted, data)
+	switch {
+	case fmtCheck:
+		if changed {
+			fmt.Println(filename)
+		}
+	case fmtWrite:
+		if changed {
+			glog.V(3).Infof("formatting %s", filename)
+			info, err := os.Stat(filename)
+			if err != nil {
+				return false, serrors.ContextualizeErrorf(err, "reading %s", filename)
+			}
+			err = ioutil.WriteFile(filename, formatted, info.Mode())
+			if err != nil {
+				return false, serrors.ContextualizeErrorf(err, "writing %s", filename)
+			}
+		}
+	default:
+		_, err = os.Stdout.Write(formatted)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return changed, nil
+}
+
+// formatDocuments formats each koki document in data with client.CanonicalKokiDocument, keeping its comments.
+// Kube-native documents are kept as they are, and reported on stderr.
+func formatDocuments(filename string, data []byte) ([]byte, error) {
+	objs, maps, err := parser.ParseSource(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(maps) != len(objs) {
+		return nil, serrors.InvalidValueErrorf(filename, "couldn't map each document to its source, so its comments can't be kept")
+	}
+
+	buf := &bytes.Buffer{}
+	for i, obj := range objs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+
+		format, err := client.DetectFormat(obj)
+		if err != nil {
+			return nil, parser.LocateError(err, maps[i])
+		}
+		if format == client.FormatKube {
+			fmt.Fprintf(os.Stderr, "%s: skipping kube-native document %d (convert it with 'short -f' first)\n", maps[i].Root, i)
+			err = client.WriteObjsToYamlStream([]interface{}{&client.SourceDocument{Obj: obj, Source: maps[i].Source}}, buf)
+			if err != nil {
+				return nil, parser.LocateError(err, maps[i])
+			}
+			continue
+		}
+
+		source := maps[i].Source
+		if source == nil {
+			// A JSON document is the whole input.
+			source = data
+		}
+		b, err := client.CanonicalKokiDocument(obj, source, client.NewDocumentComments(obj, maps[i].Comments))
+		if err != nil {
+			return nil, parser.LocateError(err, maps[i])
+		}
+		buf.Write(b)
+	}
+
+	return buf.Bytes(), nil
+}
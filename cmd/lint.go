@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+
+	"github.com/koki/json"
+	"github.com/koki/short/client"
+	"github.com/koki/short/imports"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+var (
+	lintCmd = &cobra.Command{
+		Use:   "lint",
+		Short: "Check manifests for risky settings",
+		Long: `Lint checks every document in the input against built-in best-practice rules.
+
+Kube-native and koki documents can be mixed. Koki modules are evaluated, and kube-native documents are
+converted to koki, so each rule checks the same koki fields for both. Findings are reported with the koki
+path they're about, and the position of that field (or of its closest ancestor) in the input.
+Documents that can't be linted (e.g. because they're invalid) are reported as errors, and the rest of the
+input is still linted. The command exits non-zero if there are any findings or errors.
+
+Rules:
+` + client.LintRulesHelp() + `
+
+Every rule is enabled by default. Use --config to disable some of them, with a file like:
+
+  rules:
+    probes: false
+    resources: false
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			err := lint(c, args)
+			if err != nil {
+				return errors.New(serrors.PrettyError(err))
+			}
+
+			return nil
+		},
+		SilenceUsage: true,
+		Example: `
+  # Lint every manifest under a directory tree
+  short lint -R -f manifests/
+
+  # Lint with some rules disabled
+  short lint -R -f manifests/ --config lint.yaml
+
+  # Report findings as json (e.g. for annotating pull requests)
+  short lint -R -f manifests/ --format json
+`,
+	}
+
+	// lintConfigFile is the file that enables and disables lint rules
+	lintConfigFile string
+	// lintFormat denotes the format of the lint report
+	lintFormat string
+)
+
+// lintReport is the --format json output of the lint subcommand.
+type lintReport struct {
+	Files     int                  `json:"files"`
+	Documents int                  `json:"documents"`
+	Findings  []client.LintFinding `json:"findings"`
+	Skipped   []lintSkip           `json:"skipped"`
+
+	// Errors are about the documents that couldn't be linted, and the files that couldn't be read or parsed.
+	Errors      []client.DocumentError `json:"errors"`
+	Failed      int                    `json:"failed_documents"`
+	FailedFiles int                    `json:"failed_files"`
+}
+
+// fail records that a document (or the whole file, if document is -1) couldn't be linted.
+// sourceMap is the document's SourceMap, if it has one.
+func (r *lintReport) fail(filename string, document int, sourceMap *parser.SourceMap, err error) {
+	if document < 0 {
+		r.FailedFiles++
+	} else {
+		r.Failed++
+	}
+	r.Errors = append(r.Errors, client.NewDocumentErrors(filename, document, sourceMap, err)...)
+}
+
+func init() {
+	lintCmd.Flags().StringSliceVarP(&filenames, "filenames", "f", nil, "path or url to input files to lint")
+	lintCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "process the directories in -f recursively")
+	lintCmd.Flags().StringSliceVarP(&includeGlobs, "include", "", nil, "only read files from directories if they match one of these globs")
+	lintCmd.Flags().StringSliceVarP(&excludeGlobs, "exclude", "", nil, "skip files in directories that match any of these globs")
+	lintCmd.Flags().StringVarP(&lintConfigFile, "config", "", "", "file that enables and disables lint rules")
+	lintCmd.Flags().StringVarP(&lintFormat, "format", "", "text", "report format (text*|json)")
+	lintCmd.Flags().BoolVarP(&verboseErrors, "verbose-errors", "", false, "include more information in errors")
+	lintCmd.Flags().StringVarP(&lockFile, "lock-file", "", imports.DefaultLockFile, "file that pins the versions and sha256 digests of remote modules")
+	lintCmd.Flags().StringVarP(&baseDir, "base-dir", "", "", "resolve imports in koki documents from stdin relative to this directory (default: the working directory)")
+	lintCmd.Flags().StringVarP(&moduleRoot, "module-root", "", "", "only read local modules (including symlink targets) inside this directory")
+	lintCmd.Flags().StringVarP(&moduleCacheDir, "module-cache", "", "", "directory for downloaded modules (default: the user cache directory)")
+	addParamFlags(lintCmd)
+
+	RootCmd.AddCommand(lintCmd)
+}
+
+func lint(c *cobra.Command, args []string) error {
+	serrors.SetVerboseErrors(verboseErrors)
+	glog.V(3).Infof("linting command %q", args)
+
+	useStdin := false
+	if len(args) == 1 && args[0] == "-" && len(filenames) == 0 {
+		useStdin = true
+	} else if len(args) > 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected values %q", args)
+	}
+
+	if !useStdin && len(filenames) == 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "expected -f or '-' for stdin")
+	}
+
+	if len(baseDir) > 0 && !useStdin {
+		return serrors.UsageErrorf(c.CommandPath(), "--base-dir requires '-' for stdin")
+	}
+
+	if lintFormat != "text" && lintFormat != "json" {
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected value %s for --format", lintFormat)
+	}
+
+	var config *client.LintConfig
+	if len(lintConfigFile) > 0 {
+		var err error
+		config, err = client.ReadLintConfig(lintConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	params, err := rootParams()
+	if err != nil {
+		return err
+	}
+
+	// Remote modules are checked against the lock file, but linting doesn't update it.
+	evalContext, _, err := kokiEvalContext()
+	if err != nil {
+		return err
+	}
+
+	report := &lintReport{Skipped: []lintSkip{}, Errors: []client.DocumentError{}}
+	docs := []client.LintDocument{}
+	if useStdin {
+		report.Files++
+		filename, objs, maps, err := readStdinDocuments(baseDir)
+		if err != nil {
+			report.fail(filename, -1, nil, err)
+		} else {
+			docs = append(docs, lintDocuments(evalContext, filename, objs, maps, params, report)...)
+		}
+	} else {
+		paths, err := parser.ExpandPaths(filenames, parser.PathOptions{
+			Recursive: recursive,
+			Include:   includeGlobs,
+			Exclude:   excludeGlobs,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			report.Files++
+			objs, maps, err := readInputDocuments(path)
+			if err != nil {
+				report.fail(path, -1, nil, err)
+				continue
+			}
+
+			docs = append(docs, lintDocuments(evalContext, path, objs, maps, params, report)...)
+		}
+	}
+
+	err = params.checkUsed()
+	if err != nil {
+		return err
+	}
+
+	// Services are checked against the pods in every input, so lint them all at once.
+	report.Documents = len(docs)
+	report.Findings = client.Lint(docs, config)
+
+	if lintFormat == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return serrors.InvalidInstanceContextErrorf(err, report, "marshalling lint report to JSON")
+		}
+		fmt.Printf("%s\n", string(b))
+	} else {
+		for _, skip := range report.Skipped {
+			fmt.Fprintln(os.Stderr, skip.String())
+		}
+		for _, docErr := range report.Errors {
+			fmt.Println(docErr.Error())
+		}
+		for _, finding := range report.Findings {
+			fmt.Println(finding.String())
+		}
+	}
+
+	if report.FailedFiles > 0 {
+		return fmt.Errorf("found %d problems in %d documents, %d documents couldn't be linted, and %d of %d files couldn't be read", len(report.Findings), report.Documents, report.Failed, report.FailedFiles, report.Files)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("found %d problems in %d documents (%d files), and %d documents couldn't be linted", len(report.Findings), report.Documents, report.Files, report.Failed)
+	}
+	if len(report.Findings) > 0 {
+		return fmt.Errorf("found %d problems in %d documents (%d files)", len(report.Findings), report.Documents, report.Files)
+	}
+
+	if lintFormat == "text" {
+		fmt.Fprintf(os.Stderr, "no problems found in %d documents (%d files, %d modules skipped)\n", report.Documents, report.Files, len(report.Skipped))
+	}
+
+	return nil
+}
+
+// lintDocuments converts the documents in a file to typed koki objects, like the root command.
+// Koki modules are evaluated with params, so the rules check what they render. Modules with
+// required params that aren't set can't be evaluated, so they're skipped.
+// Documents that can't be converted are recorded in report, and the others are still linted.
+// Errors and findings are located with maps.
+func lintDocuments(evalContext *imports.EvalContext, filename string, objs []map[string]interface{}, maps parser.SourceMaps, params *paramValues, report *lintReport) []client.LintDocument {
+	found := make([]*client.LintDocument, len(objs))
+	kokiObjs := make([]map[string]interface{}, len(objs))
+	for i, obj := range objs {
+		format, err := client.DetectFormat(obj)
+		if err != nil {
+			report.fail(filename, i, maps.Document(i), parser.LocateError(err, maps.Document(i)))
+			continue
+		}
+		if format == client.FormatKoki {
+			kokiObjs[i] = obj
+			continue
+		}
+
+		kokiObj, err := convertKubeDocument(obj, client.FormatKoki)
+		if err != nil {
+			report.fail(filename, i, maps.Document(i), parser.LocateError(err, maps.Document(i)))
+			continue
+		}
+		found[i] = &client.LintDocument{Filename: filename, Document: i, Input: obj, Obj: kokiObj, SourceMap: maps.Document(i)}
+	}
+
+	// The koki modules in a file are parsed together, since they can import each other.
+	modules, err := evalContext.ParseObjects(filename, kokiObjs, maps)
+	if err != nil {
+		report.fail(filename, -1, nil, err)
+		modules = nil
+	}
+
+	for i := range modules {
+		module := &modules[i]
+		if missing := module.MissingParams(declaredParams(*module, params)); len(missing) > 0 {
+			report.Skipped = append(report.Skipped, newLintSkip(*module, missing))
+			continue
+		}
+
+		err = evaluateKokiModule(evalContext, module, params)
+		if err != nil {
+			report.fail(filename, module.Document, module.SourceMap, err)
+			continue
+		}
+		kokiObj, err := convertKokiModule(*module, client.FormatKoki)
+		if err != nil {
+			report.fail(filename, module.Document, module.SourceMap, err)
+			continue
+		}
+		found[module.Document] = &client.LintDocument{
+			Filename:  filename,
+			Document:  module.Document,
+			Input:     objs[module.Document],
+			Obj:       kokiObj,
+			SourceMap: module.SourceMap,
+		}
+	}
+
+	docs := []client.LintDocument{}
+	for _, doc := range found {
+		if doc != nil {
+			docs = append(docs, *doc)
+		}
+	}
+
+	return docs
+}
+
+// lintSkip is a koki module that wasn't linted, because it has required params that aren't set.
+type lintSkip struct {
+	Filename string   `json:"file"`
+	Document int      `json:"document"`
+	Line     int      `json:"line,omitempty"`
+	Params   []string `json:"params"`
+}
+
+func newLintSkip(module imports.Module, params []string) lintSkip {
+	skip := lintSkip{Filename: module.Path, Document: module.Document, Params: params}
+	if module.SourceMap != nil {
+		skip.Line = module.SourceMap.Root.Line
+	}
+
+	return skip
+}
+
+func (s lintSkip) String() string {
+	location := s.Filename
+	if s.Line > 0 {
+		location = parser.Position{Filename: s.Filename, Line: s.Line}.String()
+	}
+
+	return fmt.Sprintf("%s: document %d: skipped, because its required params (%s) aren't set (use --set or --values)", location, s.Document, strings.Join(s.Params, ", "))
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/koki/short/client"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// marshalObjs sorts, optionally wraps, and serializes converted objects
+// in the requested output format. YAML output keeps the objects' comments.
+func marshalObjs(objs []interface{}, comments client.ObjComments) ([]byte, error) {
+	objs, err := client.SortObjs(objs, client.SortOrder(sortOrder))
+	if err != nil {
+		return nil, err
+	}
+
+	if wrapList {
+		list, err := client.WrapObjsInList(objs)
+		if err != nil {
+			return nil, err
+		}
+		objs = []interface{}{list}
+	}
+
+	buf := &bytes.Buffer{}
+	if strings.ToLower(output) == "yaml" {
+		glog.V(3).Info("marshalling converted data into yaml")
+		err = client.WriteCommentedObjsToYamlStream(objs, comments, buf)
+	} else {
+		glog.V(3).Info("marshalling converted data into json")
+		err = client.WriteObjsToJSONStream(objs, buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// withTrailingNewline ends file contents with a newline, as JSON output doesn't.
+func withTrailingNewline(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		return append(b, '\n')
+	}
+
+	return b
+}
+
+// outputExt is the file extension for the requested output format.
+// YAML output keeps the input's extension if it's already a YAML extension.
+func outputExt(filename string) string {
+	ext := filepath.Ext(filename)
+	if strings.ToLower(output) == "json" {
+		return ".json"
+	}
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		return ext
+	default:
+		return ".yaml"
+	}
+}
+
+func writeOutputFile(filename string, data []byte) error {
+	glog.V(3).Infof("writing converted data to %s", filename)
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return serrors.ContextualizeErrorf(err, "creating directory for %s", filename)
+	}
+
+	err = ioutil.WriteFile(filename, withTrailingNewline(data), 0644)
+	if err != nil {
+		return serrors.ContextualizeErrorf(err, "writing %s", filename)
+	}
+
+	return nil
+}
+
+// writeInPlace overwrites each input file with its converted contents,
+// first copying the original to a backup file if --backup-suffix is set.
+func writeInPlace(results []client.FileResult) error {
+	for _, result := range results {
+		if parser.IsURL(result.Filename) {
+			return serrors.InvalidValueErrorf(result.Filename, "can't convert a url in place")
+		}
+	}
+
+	for _, result := range results {
+		b, err := marshalObjs(result.Objs, result.Comments)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, result.Filename)
+		}
+
+		info, err := os.Stat(result.Filename)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "reading %s", result.Filename)
+		}
+
+		if len(backupSuffix) > 0 {
+			original, err := ioutil.ReadFile(result.Filename)
+			if err != nil {
+				return serrors.ContextualizeErrorf(err, "reading %s", result.Filename)
+			}
+
+			backup := result.Filename + backupSuffix
+			err = ioutil.WriteFile(backup, original, info.Mode())
+			if err != nil {
+				return serrors.ContextualizeErrorf(err, "writing backup %s", backup)
+			}
+		}
+
+		glog.V(3).Infof("overwriting %s with converted data", result.Filename)
+		err = ioutil.WriteFile(result.Filename, withTrailingNewline(b), info.Mode())
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "writing %s", result.Filename)
+		}
+	}
+
+	return nil
+}
+
+// writeFilesToDir writes each converted file to dir at the same relative
+// path it had in its input directory.
+func writeFilesToDir(results []client.FileResult, inputFiles []parser.InputFile, dir string) error {
+	filenames := make([]string, len(results))
+	written := map[string]string{}
+	for i, result := range results {
+		rel := inputFiles[i].Rel
+		rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + outputExt(rel)
+		filenames[i] = filepath.Join(dir, filepath.FromSlash(rel))
+		if previous, ok := written[filenames[i]]; ok {
+			return serrors.InvalidValueErrorf(result.Filename, "output file %s would overwrite the output for %s", filenames[i], previous)
+		}
+		written[filenames[i]] = result.Filename
+	}
+
+	for i, result := range results {
+		b, err := marshalObjs(result.Objs, result.Comments)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, result.Filename)
+		}
+
+		err = writeOutputFile(filenames[i], b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeObjsToDir writes each converted object to its own file in dir.
+// (See client.ObjFilename.)
+func writeObjsToDir(results []client.FileResult, dir string) error {
+	filenames := []string{}
+	objs := []interface{}{}
+	comments := client.ObjComments{}
+	written := map[string]string{}
+	for _, result := range results {
+		comments.Merge(result.Comments)
+		for _, obj := range result.Objs {
+			name, err := client.ObjFilename(obj, outputExt(""))
+			if err != nil {
+				return serrors.ContextualizeErrorf(err, result.Filename)
+			}
+
+			filename := filepath.Join(dir, name)
+			if previous, ok := written[filename]; ok {
+				return serrors.InvalidValueErrorf(result.Filename, "an object from %s is already written to %s", previous, filename)
+			}
+			written[filename] = result.Filename
+
+			filenames = append(filenames, filename)
+			objs = append(objs, obj)
+		}
+	}
+
+	for i, obj := range objs {
+		b, err := marshalObjs([]interface{}{obj}, comments)
+		if err != nil {
+			return err
+		}
+
+		err = writeOutputFile(filenames[i], b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koki/short/imports"
+	"github.com/koki/short/parser"
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+var (
+	// paramValuesFiles are yaml files of params for the root modules
+	paramValuesFiles []string
+	// paramEnvPrefix denotes that environment variables starting with this prefix are params for the root modules
+	paramEnvPrefix string
+	// paramFileAssignments are name=path params for the root modules, whose values are the contents of the files
+	paramFileAssignments []string
+	// paramAssignments are name=value params for the root modules
+	paramAssignments []string
+)
+
+func addParamFlags(c *cobra.Command) {
+	c.Flags().StringArrayVarP(&paramValuesFiles, "values", "", nil, "yaml file of params for the root modules (can be repeated, later files win)")
+	c.Flags().StringVarP(&paramEnvPrefix, "params-from-env", "", "", "read params for the root modules from environment variables with this prefix")
+	c.Flags().StringArrayVarP(&paramFileAssignments, "set-file", "", nil, "set a param for the root modules to the contents of a file (name=path)")
+	c.Flags().StringArrayVarP(&paramAssignments, "set", "", nil, "set a param for the root modules (name=value, or name.key=value for nested values)")
+}
+
+// paramValues are the params for the root modules.
+type paramValues struct {
+	values map[string]interface{}
+
+	// texts are the unparsed values of the params set by --set or environment variables.
+	// A module that declares one of them as a string gets its text, so --set tag=1.10 isn't the number 1.1.
+	texts map[string]string
+
+	// used are the params that a root module declares, and modules counts the root modules. (See declaredParams.)
+	used    map[string]bool
+	modules int
+}
+
+// rootParams merges the params for the root modules from each source, in
+// increasing order of precedence: --values files (in order), environment
+// variables, --set-file, and --set. Later values win, and maps are deep-merged.
+func rootParams() (*paramValues, error) {
+	params := &paramValues{
+		values: map[string]interface{}{},
+		texts:  map[string]string{},
+		used:   map[string]bool{},
+	}
+	for _, filename := range paramValuesFiles {
+		values, err := readParamValuesFile(filename)
+		if err != nil {
+			return params, err
+		}
+		params.merge(values)
+	}
+
+	if len(paramEnvPrefix) > 0 {
+		values, err := imports.ParamsFromEnv(os.Environ(), paramEnvPrefix)
+		if err != nil {
+			return params, err
+		}
+		params.merge(values)
+		for _, env := range os.Environ() {
+			if strings.HasPrefix(env, paramEnvPrefix) {
+				name, text, _ := imports.SplitParamAssignment(strings.TrimPrefix(env, paramEnvPrefix))
+				params.texts[name] = text
+			}
+		}
+	}
+
+	for _, assignment := range paramFileAssignments {
+		name, filename, err := imports.SplitParamAssignment(assignment)
+		if err != nil {
+			return params, serrors.ContextualizeErrorf(err, "--set-file")
+		}
+		contents, err := readParamFile(filename)
+		if err != nil {
+			return params, serrors.ContextualizeErrorf(err, "--set-file %s", assignment)
+		}
+		values, err := imports.ParamAtPath(name, contents)
+		if err != nil {
+			return params, serrors.ContextualizeErrorf(err, "--set-file %s", assignment)
+		}
+		params.merge(values)
+	}
+
+	for _, assignment := range paramAssignments {
+		values, err := imports.ParseParamAssignment(assignment)
+		if err != nil {
+			return params, serrors.ContextualizeErrorf(err, "--set")
+		}
+		params.merge(values)
+
+		name, text, _ := imports.SplitParamAssignment(assignment)
+		if !strings.Contains(name, ".") {
+			params.texts[name] = text
+		}
+	}
+
+	return params, nil
+}
+
+// merge merges values into params. Their texts are forgotten, since they're replaced.
+func (params *paramValues) merge(values map[string]interface{}) {
+	params.values = imports.MergeParams(params.values, values)
+	for name := range values {
+		delete(params.texts, name)
+	}
+}
+
+// checkUsed returns an error for the params that no root module declared, e.g. a typo in --set.
+func (params *paramValues) checkUsed() error {
+	unused := []string{}
+	for name := range params.values {
+		if !params.used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+
+	sort.Strings(unused)
+	if params.modules == 0 {
+		return fmt.Errorf("params (%s) are only used by koki modules that are evaluated, and none were", strings.Join(unused, ", "))
+	}
+
+	return fmt.Errorf("no root module declares the params (%s)", strings.Join(unused, ", "))
+}
+
+func readParamFile(filename string) (string, error) {
+	stream, err := parser.OpenStream(filename)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	b, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return "", serrors.ContextualizeErrorf(err, "reading %s", filename)
+	}
+
+	return string(b), nil
+}
+
+func readParamValuesFile(filename string) (map[string]interface{}, error) {
+	contents, err := readParamFile(filename)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "--values")
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal([]byte(contents), &values)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, filename, "--values should be a yaml map of params")
+	}
+
+	return values, nil
+}
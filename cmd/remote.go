@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koki/short/imports"
+	"github.com/koki/short/parser"
+)
+
+var (
+	// lockFile pins the versions and digests of remote modules
+	lockFile string
+	// moduleCacheDir stores downloaded modules and cloned git repositories
+	moduleCacheDir string
+	// moduleRoot is the directory that local modules must be in
+	moduleRoot string
+	// updateLock denotes that remote modules should be resolved again instead of using the versions in lockFile
+	updateLock bool
+)
+
+func addModuleFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&lockFile, "lock-file", "", imports.DefaultLockFile, "file that pins the versions and sha256 digests of remote modules")
+	c.Flags().StringVarP(&moduleCacheDir, "module-cache", "", "", "directory for downloaded modules (default: the user cache directory)")
+	c.Flags().StringVarP(&moduleRoot, "module-root", "", "", "only read local modules (including symlink targets) inside this directory")
+	c.Flags().BoolVarP(&updateLock, "update-lock", "", false, "resolve remote modules again and update the lock file")
+}
+
+// remoteModules reads https:// and git+ modules, pinned by the lock file.
+func remoteModules() (*imports.RemoteModules, error) {
+	lock, err := imports.ReadLockFile(lockFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDir := moduleCacheDir
+	if len(cacheDir) == 0 {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			userCacheDir = os.TempDir()
+		}
+		cacheDir = filepath.Join(userCacheDir, "short", "modules")
+	}
+
+	return &imports.RemoteModules{
+		Sources: []imports.RemoteSource{
+			&imports.HTTPSource{Opener: parser.DefaultURLOpener},
+			&imports.GitSource{CacheDir: cacheDir},
+		},
+		CacheDir: cacheDir,
+		Lock:     lock,
+		Update:   updateLock,
+	}, nil
+}
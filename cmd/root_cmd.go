@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
 	"os"
@@ -47,28 +46,71 @@ Full documentation available at https://docs.koki.io/short
   # Read from url
   short -f http://spec.com/pod.yaml
 
+  # Read every manifest under a directory tree, skipping tests
+  short -R -f manifests/ --exclude '*_test.yaml'
+
   # Convert shorthand file to native syntax
   short --kube-native -f pod_short.yaml
   short -k -f pod_short.yaml
 
+  # Convert a directory with both kube-native and shorthand files to one syntax
+  short -R -f manifests/ --to kube
+
   # Output to file
   short -f pod.yaml > pod_short.yaml
 
+  # Convert a directory tree into another directory tree
+  short -R -f manifests/ --output-dir short-manifests/
+
+  # Write one file per object
+  short -k -f app.short.yaml --output-dir out/ --split
+
+  # Convert files in place, keeping backups
+  short -f pod.yaml --in-place --backup-suffix .orig
+
+  # Render a module for an environment
+  short -k -f app.short.yaml --values prod.yaml --set replicas=5
+
   # Output as yaml* or json
   short -f pod.yaml -o json
+
+  # Convert a kubectl export and wrap the kube-native result in a single List
+  kubectl get deploy -o yaml | short - | short -k --list -
 `,
 	}
 
 	// kubeNative denotes that the conversion must output in kubernetes native syntax
 	kubeNative bool
+	// to denotes the syntax that every document is converted to (kube|koki)
+	to string
 	// filenames holds the input files that are to be converted to shorthand or kuberenetes native syntax
 	filenames []string
+	// recursive denotes that directories in filenames should be searched recursively
+	recursive bool
+	// includeGlobs restricts the files read from directories to those matching at least one glob
+	includeGlobs []string
+	// excludeGlobs skips files in directories that match any of these globs
+	excludeGlobs []string
 	// output denotes the destination of the converted data
 	output string
+	// sortOrder denotes how converted objects are ordered in the output
+	sortOrder string
+	// outputDir denotes a directory to write converted files into instead of stdout
+	outputDir string
+	// splitObjects denotes that each object is written to its own file in outputDir
+	splitObjects bool
+	// inPlace denotes that each input file is overwritten with its converted contents
+	inPlace bool
+	// backupSuffix is appended to the name of a copy of each input file made before it is overwritten
+	backupSuffix string
+	// wrapList denotes that kube-native output should be wrapped in a single v1.List
+	wrapList bool
 	// dryRun denotes that none of the activate installed should be invoked
 	dryRun bool
 	// verboseErrors denotes that error messages should contain full information instead of just a summary
 	verboseErrors bool
+	// baseDir is the directory that imports in koki modules from stdin are relative to
+	baseDir string
 	// debugImportsDepth is the number of levels of imports to output debug info for
 	debugImportsDepth int
 )
@@ -80,12 +122,25 @@ const (
 
 func init() {
 	// local flags to root command
-	RootCmd.Flags().BoolVarP(&kubeNative, "kube-native", "k", false, "convert to kube-native syntax")
+	RootCmd.Flags().BoolVarP(&kubeNative, "kube-native", "k", false, "convert to kube-native syntax (same as --to kube)")
+	RootCmd.Flags().StringVarP(&to, "to", "", "", "convert every document to this syntax (kube|koki*), passing through documents that already use it")
 	RootCmd.Flags().StringSliceVarP(&filenames, "filenames", "f", nil, "path or url to input files to read manifests")
+	RootCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "process the directories in -f recursively")
+	RootCmd.Flags().StringSliceVarP(&includeGlobs, "include", "", nil, "only read files from directories if they match one of these globs")
+	RootCmd.Flags().StringSliceVarP(&excludeGlobs, "exclude", "", nil, "skip files in directories that match any of these globs")
 	RootCmd.Flags().StringVarP(&output, "output", "o", "yaml", "output format (yaml*|json)")
+	RootCmd.Flags().StringVarP(&sortOrder, "sort", "", "", "order output by 'kind' (kind/namespace/name) or 'install' (dependencies first) instead of input order")
+	RootCmd.Flags().StringVarP(&outputDir, "output-dir", "", "", "write converted files into this directory, mirroring the input tree")
+	RootCmd.Flags().BoolVarP(&splitObjects, "split", "", false, "with --output-dir, write each object to its own file named <kind>-<namespace>-<name>")
+	RootCmd.Flags().BoolVarP(&inPlace, "in-place", "i", false, "overwrite each input file with its converted contents")
+	RootCmd.Flags().StringVarP(&backupSuffix, "backup-suffix", "", "", "with --in-place, keep a copy of each original file with this suffix appended to its name")
+	RootCmd.Flags().BoolVarP(&wrapList, "list", "", false, "wrap kube-native output in a single v1 List (requires --to kube)")
+	addParamFlags(RootCmd)
 	RootCmd.Flags().BoolVarP(&dryRun, "dry-run", "r", false, "do not invoke any installers")
 	RootCmd.Flags().BoolVarP(&verboseErrors, "verbose-errors", "", false, "include more information in errors")
 	RootCmd.Flags().IntVarP(&debugImportsDepth, "debug-imports-depth", "", defaultDebugImportsDepth, "how many levels of imports to output debug info for")
+	RootCmd.Flags().StringVarP(&baseDir, "base-dir", "", "", "resolve imports in koki modules from stdin relative to this directory (default: the working directory)")
+	addModuleFlags(RootCmd)
 
 	// parse the go default flagset to get flags for glog and other packages in future
 	RootCmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
@@ -123,85 +178,141 @@ func short(c *cobra.Command, args []string) error {
 		return serrors.UsageErrorf("unexpected value %s for -o --output", output)
 	}
 
+	switch client.SortOrder(sortOrder) {
+	case client.SortNone, client.SortByKind, client.SortForInstall:
+	default:
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected value %s for --sort", sortOrder)
+	}
+
+	target := client.FormatKoki
+	if kubeNative {
+		target = client.FormatKube
+	}
+	switch client.Format(to) {
+	case "":
+	case client.FormatKube, client.FormatKoki:
+		if kubeNative && client.Format(to) != client.FormatKube {
+			return serrors.UsageErrorf(c.CommandPath(), "--kube-native can't be used with --to %s", to)
+		}
+		target = client.Format(to)
+	default:
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected value %s for --to", to)
+	}
+
+	if wrapList && target != client.FormatKube {
+		return serrors.UsageErrorf(c.CommandPath(), "--list requires --to kube (or --kube-native)")
+	}
+
+	if splitObjects && len(outputDir) == 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "--split requires --output-dir")
+	}
+
+	if splitObjects && wrapList {
+		return serrors.UsageErrorf(c.CommandPath(), "--split can't be used with --list")
+	}
+
+	if len(backupSuffix) > 0 && !inPlace {
+		return serrors.UsageErrorf(c.CommandPath(), "--backup-suffix requires --in-place")
+	}
+
+	if inPlace && len(outputDir) > 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "--in-place can't be used with --output-dir")
+	}
+
 	useStdin := false
 	if len(args) == 1 && args[0] == "-" {
 		glog.V(3).Info("using stdin for input data")
 		useStdin = true
 	}
 
-	var convertedData []interface{}
-	if !useStdin && kubeNative {
-		// Imports are only supported for normal files in koki syntax.
-		kokiModules, err := loadKokiFiles(filenames)
-		if err != nil {
-			return err
-		}
+	if len(baseDir) > 0 && !useStdin {
+		return serrors.UsageErrorf(c.CommandPath(), "--base-dir requires input from stdin (-)")
+	}
 
-		convertedData, err = convertKokiModules(kokiModules)
-		if err != nil {
-			return err
-		}
-	} else {
-		// parse input data from one of the sources - files or stdin
-		glog.V(3).Info("parsing input data")
-		fileDatas := map[string][]map[string]interface{}{}
-		if useStdin {
-			fileDatas["stdin"], err = parser.Parse(nil, true)
-			if err != nil {
-				return fmt.Errorf("parsing stdin: %s", err.Error())
-			}
-		} else {
-			for _, filename := range filenames {
-				fileDatas[filename], err = parser.Parse([]string{filename}, false)
-				if err != nil {
-					return fmt.Errorf("parsing %s: %s", filename, err.Error())
-				}
-			}
-		}
+	inputFiles, err := parser.ExpandInputFiles(filenames, parser.PathOptions{
+		Recursive: recursive,
+		Include:   includeGlobs,
+		Exclude:   excludeGlobs,
+	})
+	if err != nil {
+		return err
+	}
+
+	if useStdin && inPlace {
+		return serrors.UsageErrorf(c.CommandPath(), "--in-place requires input files")
+	}
 
-		i := 0
-		convertedData = []interface{}{}
+	if useStdin && len(outputDir) > 0 && !splitObjects {
+		return serrors.UsageErrorf(c.CommandPath(), "--output-dir with stdin input requires --split")
+	}
 
-		for filename, data := range fileDatas {
-			if err != nil {
-				return err
-			}
+	// Koki documents are modules, so their imports and params are evaluated.
+	params, err := rootParams()
+	if err != nil {
+		return err
+	}
 
-			if kubeNative {
-				glog.V(3).Info("converting input to kubernetes native syntax")
-				objs, err := client.ConvertKokiMaps(data)
-				if err != nil {
-					return fmt.Errorf("converting %s: %s", filename, err.Error())
-				}
-				convertedData = append(convertedData, objs...)
-			} else {
-				glog.V(3).Info("converting input to koki native syntax")
-				objs, err := client.ConvertKubeMaps(data)
-				if err != nil {
-					return fmt.Errorf("converting %s: %s", filename, err.Error())
-				}
-				convertedData = append(convertedData, objs...)
-			}
-			i = i + 1
-		}
+	evalContext, lock, err := kokiEvalContext()
+	if err != nil {
+		return err
 	}
 
-	buf := &bytes.Buffer{}
-	if strings.ToLower(output) == "yaml" {
-		glog.V(3).Info("marshalling converted data into yaml")
-		err = client.WriteObjsToYamlStream(convertedData, buf)
+	var results []client.FileResult
+	if useStdin {
+		glog.V(3).Infof("converting stdin to %s syntax", target)
+		result, err := convertStdin(evalContext, baseDir, target, params)
 		if err != nil {
 			return err
 		}
-	} else {
-		glog.V(3).Info("marshalling converted data into json")
-		err = client.WriteObjsToJSONStream(convertedData, buf)
+
+		result.Filename = "stdin"
+		results = append(results, result)
+	}
+
+	for _, inputFile := range inputFiles {
+		glog.V(3).Infof("converting (%s) to %s syntax", inputFile.Path, target)
+		result, err := convertFile(evalContext, inputFile.Path, target, params)
 		if err != nil {
 			return err
 		}
+
+		results = append(results, result)
+	}
+
+	err = params.checkUsed()
+	if err != nil {
+		return err
+	}
+
+	// Pin any new remote modules, now that they've all loaded.
+	err = lock.Write()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case inPlace:
+		return writeInPlace(results)
+	case len(outputDir) > 0 && splitObjects:
+		return writeObjsToDir(results, outputDir)
+	case len(outputDir) > 0:
+		return writeFilesToDir(results, inputFiles, outputDir)
+	}
+
+	// Output follows the input file order and the document order within each file.
+	convertedData := []interface{}{}
+	comments := client.ObjComments{}
+	for _, result := range results {
+		convertedData = append(convertedData, result.Objs...)
+		comments.Merge(result.Comments)
+	}
+
+	b, err := marshalObjs(convertedData, comments)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("%s\n", buf.String())
+	fmt.Printf("%s\n", string(b))
 
 	return nil
 }
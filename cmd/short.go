@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/golang/glog"
 
 	"github.com/koki/json/jsonutil"
+	"github.com/koki/short/client"
 	"github.com/koki/short/converter"
 	"github.com/koki/short/imports"
 	"github.com/koki/short/parser"
@@ -26,62 +32,256 @@ func debugLogModule(module imports.Module) {
 	}
 }
 
-func loadKokiFiles(filenames []string) ([]imports.Module, error) {
-	results := []imports.Module{}
-	for _, filename := range filenames {
-		evalContext := imports.EvalContext{
-			RawToTyped:        parser.ParseKokiNativeObject,
-			ResolveImportPath: imports.ResolveImportLocalPath,
-			ReadFromPath:      imports.ReadFromLocalPath,
-		}
+// kokiEvalContext creates an EvalContext for loading koki modules. Reuse it for every
+// input, so each module file is only read and parsed once, even if several inputs import it.
+// Remote modules are recorded in the returned lock file, which the caller saves.
+func kokiEvalContext() (*imports.EvalContext, *imports.LockFile, error) {
+	remotes, err := remoteModules()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &imports.EvalContext{
+		RawToTyped:        parser.ParseKokiNativeObject,
+		ResolveImportPath: remotes.ResolveImportPath,
+		ReadFromPath:      remotes.ReadFromPath,
+		ParseParamKind:    parser.ParseKokiNativeValue,
+		ModuleRoot:        moduleRoot,
+	}, remotes.Lock, nil
+}
+
+// convertFile converts the documents in a file to target, like convertDocuments.
+func convertFile(evalContext *imports.EvalContext, filename string, target client.Format, params *paramValues) (client.FileResult, error) {
+	objs, maps, err := readInputDocuments(filename)
+	if err != nil {
+		return client.FileResult{}, err
+	}
+
+	return convertDocuments(evalContext, filename, objs, maps, target, params, true)
+}
+
+// readInputDocuments reads the documents in an input file or URL, and their SourceMaps. Only the remote
+// modules that inputs import are pinned by the lock file, so a URL input is fetched again every time.
+func readInputDocuments(filename string) ([]map[string]interface{}, parser.SourceMaps, error) {
+	objs, maps, err := imports.ReadFromPathOrURL(filename)
+	if err != nil {
+		return nil, nil, serrors.InvalidValueContextErrorf(err, filename, "reading input")
+	}
+
+	return objs, maps, nil
+}
+
+// convertStdin converts the documents read from stdin to target, like convertDocuments.
+// Imports in its koki modules are resolved relative to baseDir (or the working directory, if it's empty).
+func convertStdin(evalContext *imports.EvalContext, baseDir string, target client.Format, params *paramValues) (client.FileResult, error) {
+	filename, objs, maps, err := readStdinDocuments(baseDir)
+	if err != nil {
+		return client.FileResult{}, err
+	}
+
+	return convertDocuments(evalContext, filename, objs, maps, target, params, true)
+}
+
+// readStdinDocuments parses the documents read from stdin, and returns their SourceMaps. Their filename
+// is stdin in baseDir, so imports in its koki modules are resolved relative to baseDir.
+func readStdinDocuments(baseDir string) (string, []map[string]interface{}, parser.SourceMaps, error) {
+	filename := stdinFilename(baseDir)
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return filename, nil, nil, serrors.ContextualizeErrorf(err, "reading stdin")
+	}
+
+	objs, maps, err := parser.ParseSource(filename, data)
+	return filename, objs, maps, err
+}
 
-		modules, err := evalContext.Parse(filename)
+// convertDocuments converts each document to target, in document order. With passThrough, documents
+// already in the target format are output as they were written. (See client.SourceDocument.)
+// Otherwise, koki documents are modules, so their imports and params are evaluated first.
+// Each module only gets the params it declares. (See declaredParams.)
+// Errors are located with maps, and the comments of each document are kept for YAML output.
+func convertDocuments(evalContext *imports.EvalContext, filename string, objs []map[string]interface{}, maps parser.SourceMaps, target client.Format, params *paramValues, passThrough bool) (client.FileResult, error) {
+	result := client.FileResult{Filename: filename}
+	formats := make([]client.Format, len(objs))
+	kokiObjs := make([]map[string]interface{}, len(objs))
+	for i, obj := range objs {
+		format, err := client.DetectFormat(obj)
 		if err != nil {
-			return nil, err
+			return result, parser.LocateError(err, maps.Document(i))
 		}
 
-		for _, module := range modules {
-			err = evalContext.EvaluateModule(&module, nil)
-			if err != nil {
+		formats[i] = format
+		if format == client.FormatKoki && !(passThrough && target == client.FormatKoki) {
+			kokiObjs[i] = obj
+		}
+	}
+	comments := documentComments(documentSourceMaps(filename, objs, maps), objs)
+
+	modules, err := evalContext.ParseObjects(filename, kokiObjs, maps)
+	if err != nil {
+		return result, err
+	}
+
+	modules, err = evaluateKokiModules(evalContext, modules, params)
+	if err != nil {
+		return result, err
+	}
+
+	result.Objs = make([]interface{}, len(objs))
+	for _, module := range modules {
+		result.Objs[module.Document], err = convertKokiModule(module, target)
+		if err != nil {
+			if !isExtraneousFieldsError(err) {
 				debugLogModule(module)
-				return nil, err
 			}
+			return result, err
+		}
+	}
 
-			export := module.Export
-			if err, ok := export.TypedResult.(error); ok {
-				debugLogModule(module)
-				return nil, err
+	for i, obj := range objs {
+		switch {
+		case passThrough && formats[i] == target:
+			doc := &client.SourceDocument{Obj: obj}
+			if sourceMap := maps.Document(i); sourceMap != nil {
+				doc.Source = sourceMap.Source
 			}
+			result.Objs[i] = doc
+		case formats[i] == client.FormatKube:
+			result.Objs[i], err = convertKubeDocument(obj, target)
+			if err != nil {
+				return result, parser.LocateError(err, maps.Document(i))
+			}
+		}
+	}
 
-			results = append(results, module)
+	result.Comments = client.ObjComments{}
+	for i, obj := range result.Objs {
+		if i < len(comments) {
+			result.Comments.Add(obj, comments[i])
 		}
 	}
 
-	return results, nil
+	return result, nil
+}
+
+// documentSourceMaps checks that there's a SourceMap for each document in filename, or returns nil if there isn't.
+func documentSourceMaps(filename string, objs []map[string]interface{}, maps parser.SourceMaps) parser.SourceMaps {
+	if len(maps) != len(objs) {
+		glog.V(3).Infof("couldn't map the documents of (%s) to their source", filename)
+		return nil
+	}
+
+	return maps
+}
+
+// documentComments reads the comments of each document from its SourceMap, if the output is YAML.
+func documentComments(maps parser.SourceMaps, objs []map[string]interface{}) []*client.DocumentComments {
+	if maps == nil || strings.ToLower(output) != "yaml" {
+		return nil
+	}
+
+	comments := make([]*client.DocumentComments, len(objs))
+	for i, obj := range objs {
+		comments[i] = client.NewDocumentComments(obj, maps[i].Comments)
+	}
+
+	return comments
 }
 
-func convertKokiModules(kokiModules []imports.Module) ([]interface{}, error) {
-	kubeObjs := []interface{}{}
-	for _, kokiModule := range kokiModules {
-		kokiExport := kokiModule.Export
-		data := kokiExport.Raw
-		extraneousPaths, err := jsonutil.ExtraneousFieldPaths(data, kokiExport.TypedResult)
+func stdinFilename(baseDir string) string {
+	return filepath.Join(baseDir, "stdin")
+}
+
+func evaluateKokiModules(evalContext *imports.EvalContext, modules []imports.Module, params *paramValues) ([]imports.Module, error) {
+	for i := range modules {
+		err := evaluateKokiModule(evalContext, &modules[i], params)
 		if err != nil {
-			return nil, serrors.ContextualizeErrorf(err, "checking for extraneous fields in input")
+			return nil, err
 		}
-		if len(extraneousPaths) > 0 {
-			return nil, &jsonutil.ExtraneousFieldsError{
-				Paths: extraneousPaths,
-			}
+	}
+
+	return modules, nil
+}
+
+// evaluateKokiModule evaluates a root module with the params it declares, and parses its typed result.
+func evaluateKokiModule(evalContext *imports.EvalContext, module *imports.Module, params *paramValues) error {
+	err := evalContext.EvaluateModule(module, declaredParams(*module, params))
+	if err != nil {
+		debugLogModule(*module)
+		return err
+	}
+
+	if err, ok := module.Export.TypedResult.(error); ok {
+		debugLogModule(*module)
+		return err
+	}
+
+	return nil
+}
+
+// declaredParams copies the params that module declares, since evaluation adds default
+// values, and other params could shadow its imports. Params declared as strings keep the
+// text they were set with. (See paramValues.)
+func declaredParams(module imports.Module, params *paramValues) map[string]interface{} {
+	declared := map[string]interface{}{}
+	for name, val := range params.values {
+		def, ok := module.Params[name]
+		if !ok {
+			continue
 		}
 
-		kubeObj, err := converter.DetectAndConvertFromKokiObj(kokiExport.TypedResult)
-		if err != nil {
-			debugLogModule(kokiModule)
-			return nil, err
+		if text, ok := params.texts[name]; ok && def.Type == "string" {
+			val = text
 		}
-		kubeObjs = append(kubeObjs, kubeObj)
+		declared[name] = val
+		params.used[name] = true
+	}
+	params.modules++
+
+	return imports.MergeParams(nil, declared)
+}
+
+// convertKokiModule converts the export of an evaluated module to target.
+func convertKokiModule(kokiModule imports.Module, target client.Format) (interface{}, error) {
+	kokiExport := kokiModule.Export
+	data := kokiExport.Raw
+	extraneousPaths, err := jsonutil.ExtraneousFieldPaths(data, kokiExport.TypedResult)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "checking for extraneous fields in input")
+	}
+	if len(extraneousPaths) > 0 {
+		return nil, parser.LocateError(&jsonutil.ExtraneousFieldsError{
+			Paths: extraneousPaths,
+		}, kokiModule.SourceMap)
 	}
 
-	return kubeObjs, nil
+	if target == client.FormatKoki {
+		return kokiExport.TypedResult, nil
+	}
+
+	kubeObj, err := converter.DetectAndConvertFromKokiObj(kokiExport.TypedResult)
+	if err != nil {
+		return nil, parser.LocateError(err, kokiModule.SourceMap)
+	}
+
+	return kubeObj, nil
+}
+
+// convertKubeDocument converts a kube-native document to target.
+func convertKubeDocument(obj map[string]interface{}, target client.Format) (interface{}, error) {
+	if target == client.FormatKube {
+		return client.ParseKubeMap(obj)
+	}
+
+	kokiObjs, err := client.ConvertKubeMaps([]map[string]interface{}{obj})
+	if err != nil {
+		return nil, err
+	}
+
+	return kokiObjs[0], nil
+}
+
+func isExtraneousFieldsError(err error) bool {
+	_, ok := parser.BaseError(err).(*jsonutil.ExtraneousFieldsError)
+	return ok
 }
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+
+	"github.com/koki/json"
+	"github.com/koki/short/client"
+	"github.com/koki/short/imports"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+var (
+	validateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check that manifests parse and convert, without producing any output",
+		Long: `Validate checks that every document in the input parses, has no extraneous fields, and converts cleanly.
+
+Kube-native and koki documents can be mixed. Every problem is reported, and the command exits non-zero if any were found.
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			err := validate(c, args)
+			if err != nil {
+				return errors.New(serrors.PrettyError(err))
+			}
+
+			return nil
+		},
+		SilenceUsage: true,
+		Example: `
+  # Validate every manifest under a directory tree
+  short validate -R -f manifests/
+
+  # Stream in manifests
+  cat pod.yaml | short validate -
+
+  # Report errors as json (e.g. for annotating pull requests)
+  short validate -R -f manifests/ --format json
+`,
+	}
+
+	// validateFormat denotes the format of the error report
+	validateFormat string
+)
+
+// validationReport is the --format json output of the validate subcommand.
+// The documents of files that couldn't be read or parsed aren't counted; those files are FailedFiles.
+type validationReport struct {
+	Files       int                    `json:"files"`
+	Documents   int                    `json:"documents"`
+	Failed      int                    `json:"failed_documents"`
+	FailedFiles int                    `json:"failed_files"`
+	Errors      []client.DocumentError `json:"errors"`
+}
+
+func init() {
+	validateCmd.Flags().StringSliceVarP(&filenames, "filenames", "f", nil, "path or url to input files to validate")
+	validateCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "process the directories in -f recursively")
+	validateCmd.Flags().StringSliceVarP(&includeGlobs, "include", "", nil, "only read files from directories if they match one of these globs")
+	validateCmd.Flags().StringSliceVarP(&excludeGlobs, "exclude", "", nil, "skip files in directories that match any of these globs")
+	validateCmd.Flags().StringVarP(&validateFormat, "format", "", "text", "error report format (text*|json)")
+	validateCmd.Flags().BoolVarP(&verboseErrors, "verbose-errors", "", false, "include more information in errors")
+	validateCmd.Flags().StringVarP(&lockFile, "lock-file", "", imports.DefaultLockFile, "file that pins the versions and sha256 digests of remote modules")
+	validateCmd.Flags().StringVarP(&baseDir, "base-dir", "", "", "resolve imports in koki documents from stdin relative to this directory (default: the working directory)")
+	validateCmd.Flags().StringVarP(&moduleRoot, "module-root", "", "", "only read local modules (including symlink targets) inside this directory")
+	validateCmd.Flags().StringVarP(&moduleCacheDir, "module-cache", "", "", "directory for downloaded modules (default: the user cache directory)")
+
+	RootCmd.AddCommand(validateCmd)
+}
+
+func validate(c *cobra.Command, args []string) error {
+	serrors.SetVerboseErrors(verboseErrors)
+	glog.V(3).Infof("validating command %q", args)
+
+	useStdin := false
+	if len(args) == 1 && args[0] == "-" && len(filenames) == 0 {
+		useStdin = true
+	} else if len(args) > 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected values %q", args)
+	}
+
+	if !useStdin && len(filenames) == 0 {
+		return serrors.UsageErrorf(c.CommandPath(), "expected -f or '-' for stdin")
+	}
+
+	if len(baseDir) > 0 && !useStdin {
+		return serrors.UsageErrorf(c.CommandPath(), "--base-dir requires '-' for stdin")
+	}
+
+	if validateFormat != "text" && validateFormat != "json" {
+		return serrors.UsageErrorf(c.CommandPath(), "unexpected value %s for --format", validateFormat)
+	}
+
+	report := validationReport{Errors: []client.DocumentError{}}
+	// Remote modules are checked against the lock file, but validating doesn't update it.
+	evalContext, _, err := kokiEvalContext()
+	if err != nil {
+		return err
+	}
+	if useStdin {
+		report.add(validateStdin(evalContext))
+	} else {
+		paths, err := parser.ExpandPaths(filenames, parser.PathOptions{
+			Recursive: recursive,
+			Include:   includeGlobs,
+			Exclude:   excludeGlobs,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			report.add(validateFile(evalContext, path))
+		}
+	}
+
+	if validateFormat == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return serrors.InvalidInstanceContextErrorf(err, report, "marshalling validation report to JSON")
+		}
+		fmt.Printf("%s\n", string(b))
+	} else {
+		for _, docErr := range report.Errors {
+			fmt.Println(docErr.Error())
+		}
+	}
+
+	if report.FailedFiles > 0 {
+		return fmt.Errorf("found %d errors in %d of %d documents, and %d of %d files couldn't be read", len(report.Errors), report.Failed, report.Documents, report.FailedFiles, report.Files)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("found %d errors in %d of %d documents (%d files)", len(report.Errors), report.Failed, report.Documents, report.Files)
+	}
+
+	if validateFormat == "text" {
+		fmt.Fprintf(os.Stderr, "%d documents in %d files are valid\n", report.Documents, report.Files)
+	}
+
+	return nil
+}
+
+// fileValidation is the result of validating a single input.
+type fileValidation struct {
+	documents int
+	errors    [][]client.DocumentError
+}
+
+func (r *validationReport) add(result fileValidation) {
+	r.Files++
+	r.Documents += result.documents
+	for _, docErrs := range result.errors {
+		if len(docErrs) == 0 {
+			continue
+		}
+		if docErrs[0].Document < 0 {
+			r.FailedFiles++
+		} else {
+			r.Failed++
+		}
+		r.Errors = append(r.Errors, docErrs...)
+	}
+}
+
+// validateStdin resolves imports in koki documents relative to baseDir, like convertStdin.
+func validateStdin(evalContext *imports.EvalContext) fileValidation {
+	filename := stdinFilename(baseDir)
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return validateDocuments(evalContext, filename, nil, nil, serrors.ContextualizeErrorf(err, "reading stdin"))
+	}
+
+	objs, maps, err := parser.ParseSource(filename, data)
+	return validateDocuments(evalContext, filename, objs, maps, err)
+}
+
+func validateFile(evalContext *imports.EvalContext, filename string) fileValidation {
+	objs, maps, err := parser.ReadSource(filename)
+	return validateDocuments(evalContext, filename, objs, maps, err)
+}
+
+// validateDocuments checks each parsed document independently, so one bad
+// document doesn't hide problems in the others.
+// Koki documents are evaluated as modules with evalContext, or converted
+// without imports if it's nil. Errors are located with maps.
+func validateDocuments(evalContext *imports.EvalContext, filename string, objs []map[string]interface{}, maps parser.SourceMaps, parseErr error) fileValidation {
+	if parseErr != nil {
+		return fileValidation{errors: [][]client.DocumentError{client.NewDocumentErrors(filename, -1, nil, parseErr)}}
+	}
+
+	result := fileValidation{documents: len(objs)}
+	for i, obj := range objs {
+		format, err := client.DetectFormat(obj)
+		switch {
+		case err != nil:
+			// Reported as it is.
+		case format == client.FormatKube:
+			_, err = client.ConvertKubeMaps([]map[string]interface{}{obj})
+		case evalContext != nil:
+			err = validateKokiDocument(evalContext, filename, i, maps.Document(i), obj)
+		default:
+			_, err = client.ConvertKokiMaps([]map[string]interface{}{obj})
+		}
+		if err != nil {
+			result.errors = append(result.errors, client.NewDocumentErrors(filename, i, maps.Document(i), err))
+		}
+	}
+
+	return result
+}
+
+// validateKokiDocument evaluates a koki document as a module (so that its
+// imports are checked too) and then converts it.
+func validateKokiDocument(evalContext *imports.EvalContext, filename string, document int, sourceMap *parser.SourceMap, obj map[string]interface{}) error {
+	module, err := evalContext.ParseComponent(filename, obj)
+	if err != nil {
+		return parser.LocateError(err, sourceMap)
+	}
+	module.Document = document
+	module.SourceMap = sourceMap
+
+	err = evalContext.EvaluateModule(module, nil)
+	if err != nil {
+		return err
+	}
+
+	if err, ok := module.Export.TypedResult.(error); ok {
+		return err
+	}
+
+	_, err = convertKokiModule(*module, client.FormatKube)
+	return err
+}
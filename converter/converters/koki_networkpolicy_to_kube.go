@@ -0,0 +1,176 @@
+package converters
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koki/short/parser/expressions"
+	"github.com/koki/short/types"
+	serrors "github.com/koki/structurederrors"
+)
+
+func Convert_Koki_NetworkPolicy_to_Kube(wrapper *types.NetworkPolicyWrapper) (*networkingv1.NetworkPolicy, error) {
+	var err error
+	kube := &networkingv1.NetworkPolicy{}
+	koki := &wrapper.NetworkPolicy
+
+	kube.Name = koki.Name
+	kube.Namespace = koki.Namespace
+	if len(koki.Version) == 0 {
+		kube.APIVersion = "networking.k8s.io/v1"
+	} else {
+		kube.APIVersion = koki.Version
+	}
+	kube.Kind = "NetworkPolicy"
+	kube.ClusterName = koki.Cluster
+	kube.Labels = koki.Labels
+	kube.Annotations = koki.Annotations
+
+	podSelector, err := expressions.ParseLabelSelector(koki.Selector)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "network_policy selector")
+	}
+	if podSelector != nil {
+		kube.Spec.PodSelector = *podSelector
+	}
+
+	kube.Spec.Ingress, err = revertNetworkPolicyIngressRules(koki.Ingress)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "network_policy ingress")
+	}
+
+	kube.Spec.Egress, err = revertNetworkPolicyEgressRules(koki.Egress)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "network_policy egress")
+	}
+
+	kube.Spec.PolicyTypes, err = revertPolicyTypes(koki.PolicyTypes)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "network_policy policy_types")
+	}
+
+	return kube, nil
+}
+
+func revertNetworkPolicyIngressRules(kokiRules []types.NetworkPolicyIngressRule) ([]networkingv1.NetworkPolicyIngressRule, error) {
+	if kokiRules == nil {
+		return nil, nil
+	}
+
+	var err error
+	kubeRules := make([]networkingv1.NetworkPolicyIngressRule, len(kokiRules))
+	for i, kokiRule := range kokiRules {
+		kubeRules[i].Ports = revertNetworkPolicyPorts(kokiRule.Ports)
+		kubeRules[i].From, err = revertNetworkPolicyPeers(kokiRule.From)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d] from", i)
+		}
+	}
+
+	return kubeRules, nil
+}
+
+func revertNetworkPolicyEgressRules(kokiRules []types.NetworkPolicyEgressRule) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	if kokiRules == nil {
+		return nil, nil
+	}
+
+	var err error
+	kubeRules := make([]networkingv1.NetworkPolicyEgressRule, len(kokiRules))
+	for i, kokiRule := range kokiRules {
+		kubeRules[i].Ports = revertNetworkPolicyPorts(kokiRule.Ports)
+		kubeRules[i].To, err = revertNetworkPolicyPeers(kokiRule.To)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d] to", i)
+		}
+	}
+
+	return kubeRules, nil
+}
+
+func revertNetworkPolicyPorts(kokiPorts []types.NetworkPolicyPort) []networkingv1.NetworkPolicyPort {
+	if kokiPorts == nil {
+		return nil
+	}
+
+	kubePorts := make([]networkingv1.NetworkPolicyPort, len(kokiPorts))
+	for i, kokiPort := range kokiPorts {
+		kubePorts[i].Port = kokiPort.Port
+		if len(kokiPort.Protocol) > 0 {
+			protocol := revertProtocol(kokiPort.Protocol)
+			kubePorts[i].Protocol = &protocol
+		}
+	}
+
+	return kubePorts
+}
+
+func revertNetworkPolicyPeers(kokiPeers []types.NetworkPolicyPeer) ([]networkingv1.NetworkPolicyPeer, error) {
+	if kokiPeers == nil {
+		return nil, nil
+	}
+
+	var err error
+	kubePeers := make([]networkingv1.NetworkPolicyPeer, len(kokiPeers))
+	for i, kokiPeer := range kokiPeers {
+		kubePeers[i].PodSelector, err = revertOptionalLabelSelector(kokiPeer.Pods)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d] pods", i)
+		}
+
+		kubePeers[i].NamespaceSelector, err = revertOptionalLabelSelector(kokiPeer.Namespaces)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d] namespaces", i)
+		}
+
+		if len(kokiPeer.CIDR) > 0 {
+			kubePeers[i].IPBlock = &networkingv1.IPBlock{
+				CIDR:   kokiPeer.CIDR,
+				Except: kokiPeer.Except,
+			}
+		} else if len(kokiPeer.Except) > 0 {
+			return nil, serrors.InvalidInstanceErrorf(kokiPeer, "[%d] except requires cidr", i)
+		}
+	}
+
+	return kubePeers, nil
+}
+
+// revertOptionalLabelSelector distinguishes an unspecified selector (nil)
+// from one that selects everything ("").
+func revertOptionalLabelSelector(kokiSelector *string) (*metav1.LabelSelector, error) {
+	if kokiSelector == nil {
+		return nil, nil
+	}
+
+	kubeSelector, err := expressions.ParseLabelSelector(*kokiSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if kubeSelector == nil {
+		return &metav1.LabelSelector{}, nil
+	}
+
+	return kubeSelector, nil
+}
+
+func revertPolicyTypes(kokiTypes []types.PolicyType) ([]networkingv1.PolicyType, error) {
+	if len(kokiTypes) == 0 {
+		return nil, nil
+	}
+
+	kubeTypes := make([]networkingv1.PolicyType, len(kokiTypes))
+	for i, kokiType := range kokiTypes {
+		switch kokiType {
+		case types.PolicyTypeIngress:
+			kubeTypes[i] = networkingv1.PolicyTypeIngress
+		case types.PolicyTypeEgress:
+			kubeTypes[i] = networkingv1.PolicyTypeEgress
+		default:
+			return nil, serrors.InvalidInstanceErrorf(kokiType, "[%d]", i)
+		}
+	}
+
+	return kubeTypes, nil
+}
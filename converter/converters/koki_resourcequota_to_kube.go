@@ -0,0 +1,159 @@
+package converters
+
+import (
+	"strings"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/koki/short/types"
+	serrors "github.com/koki/structurederrors"
+)
+
+func Convert_Koki_ResourceQuota_to_Kube(wrapper *types.ResourceQuotaWrapper) (*v1.ResourceQuota, error) {
+	var err error
+	kube := &v1.ResourceQuota{}
+	koki := &wrapper.ResourceQuota
+
+	kube.Name = koki.Name
+	kube.Namespace = koki.Namespace
+	if len(koki.Version) == 0 {
+		kube.APIVersion = "v1"
+	} else {
+		kube.APIVersion = koki.Version
+	}
+	kube.Kind = "ResourceQuota"
+	kube.ClusterName = koki.Cluster
+	kube.Labels = koki.Labels
+	kube.Annotations = koki.Annotations
+
+	kube.Spec.Hard, err = revertQuotaResources(koki.Hard)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "resource_quota hard")
+	}
+
+	kube.Spec.Scopes, err = revertResourceQuotaScopes(koki.Scopes)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "resource_quota scopes")
+	}
+
+	if koki.Status != nil {
+		kube.Status.Hard, err = revertQuotaResources(koki.Status.Hard)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "resource_quota status hard")
+		}
+
+		kube.Status.Used, err = revertQuotaResources(koki.Status.Used)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "resource_quota status used")
+		}
+	}
+
+	return kube, nil
+}
+
+// quotaResourceNames pairs the kube-native quota resources with their koki names.
+// Memory is "mem" in koki, like in containers.
+var quotaResourceNames = [][2]v1.ResourceName{
+	{v1.ResourceCPU, "cpu"},
+	{v1.ResourceMemory, "mem"},
+	{v1.ResourceRequestsCPU, "requests.cpu"},
+	{v1.ResourceLimitsCPU, "limits.cpu"},
+	{v1.ResourceRequestsMemory, "requests.mem"},
+	{v1.ResourceLimitsMemory, "limits.mem"},
+	{v1.ResourceStorage, "storage"},
+	{v1.ResourceRequestsStorage, "requests.storage"},
+	{v1.ResourceEphemeralStorage, "ephemeral-storage"},
+	{v1.ResourceRequestsEphemeralStorage, "requests.ephemeral-storage"},
+	{v1.ResourceLimitsEphemeralStorage, "limits.ephemeral-storage"},
+	{v1.ResourcePods, "pods"},
+	{v1.ResourceServices, "services"},
+	{v1.ResourceServicesNodePorts, "services.nodeports"},
+	{v1.ResourceServicesLoadBalancers, "services.loadbalancers"},
+	{v1.ResourceReplicationControllers, "replicationcontrollers"},
+	{v1.ResourceQuotas, "resourcequotas"},
+	{v1.ResourceSecrets, "secrets"},
+	{v1.ResourceConfigMaps, "configmaps"},
+	{v1.ResourcePersistentVolumeClaims, "persistentvolumeclaims"},
+}
+
+// quotaRequirementNames are the kube-native quota resources for the requests (min)
+// and limits (max) of each container resource.
+var quotaRequirementNames = map[v1.ResourceName][2]v1.ResourceName{
+	v1.ResourceCPU:    {v1.ResourceRequestsCPU, v1.ResourceLimitsCPU},
+	v1.ResourceMemory: {v1.ResourceRequestsMemory, v1.ResourceLimitsMemory},
+}
+
+// isQualifiedQuotaResource is true for quota resources that keep their names in both syntaxes:
+// fully-qualified ones (e.g. requests.nvidia.com/gpu, gold.storageclass.storage.k8s.io/requests.storage),
+// and huge pages.
+func isQualifiedQuotaResource(name v1.ResourceName) bool {
+	return strings.Contains(string(name), "/") || strings.HasPrefix(string(name), v1.ResourceRequestsHugePagesPrefix)
+}
+
+func revertQuotaResourceName(kokiName v1.ResourceName) (v1.ResourceName, error) {
+	if isQualifiedQuotaResource(kokiName) {
+		return kokiName, nil
+	}
+
+	for _, names := range quotaResourceNames {
+		if names[1] == kokiName {
+			return names[0], nil
+		}
+	}
+
+	return "", serrors.InvalidValueErrorf(kokiName, "unknown quota resource")
+}
+
+func revertQuotaResources(kokiResources *types.QuotaResources) (v1.ResourceList, error) {
+	if kokiResources == nil {
+		return nil, nil
+	}
+
+	kubeResources := v1.ResourceList{}
+	for kokiName, q := range kokiResources.Resources {
+		name, err := revertQuotaResourceName(kokiName)
+		if err != nil {
+			return nil, err
+		}
+		kubeResources[name] = q
+	}
+
+	requirements, err := revertResources(kokiResources.CPU, kokiResources.Mem)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, q := range requirements.Requests {
+		kubeResources[quotaRequirementNames[name][0]] = q
+	}
+
+	for name, q := range requirements.Limits {
+		kubeResources[quotaRequirementNames[name][1]] = q
+	}
+
+	return kubeResources, nil
+}
+
+func revertResourceQuotaScopes(kokiScopes []types.ResourceQuotaScope) ([]v1.ResourceQuotaScope, error) {
+	if len(kokiScopes) == 0 {
+		return nil, nil
+	}
+
+	kubeScopes := make([]v1.ResourceQuotaScope, len(kokiScopes))
+	for i, kokiScope := range kokiScopes {
+		switch kokiScope {
+		case types.ResourceQuotaScopeTerminating:
+			kubeScopes[i] = v1.ResourceQuotaScopeTerminating
+		case types.ResourceQuotaScopeNotTerminating:
+			kubeScopes[i] = v1.ResourceQuotaScopeNotTerminating
+		case types.ResourceQuotaScopeBestEffort:
+			kubeScopes[i] = v1.ResourceQuotaScopeBestEffort
+		case types.ResourceQuotaScopeNotBestEffort:
+			kubeScopes[i] = v1.ResourceQuotaScopeNotBestEffort
+		default:
+			return nil, serrors.InvalidInstanceErrorf(kokiScope, "[%d]", i)
+		}
+	}
+
+	return kubeScopes, nil
+}
@@ -0,0 +1,48 @@
+package converters
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/koki/short/types"
+)
+
+func TestRevertQuotaResources(t *testing.T) {
+	kokiResources := &types.QuotaResources{
+		CPU: &types.CPU{Max: "2"},
+		Resources: v1.ResourceList{
+			"mem":                     resource.MustParse("1Gi"),
+			"pods":                    resource.MustParse("4"),
+			"requests.nvidia.com/gpu": resource.MustParse("1"),
+		},
+	}
+
+	expected := v1.ResourceList{
+		v1.ResourceLimitsCPU:      resource.MustParse("2"),
+		v1.ResourceMemory:         resource.MustParse("1Gi"),
+		v1.ResourcePods:           resource.MustParse("4"),
+		"requests.nvidia.com/gpu": resource.MustParse("1"),
+	}
+
+	kubeResources, err := revertQuotaResources(kokiResources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kubeResources, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, kubeResources)
+	}
+
+	kokiResources.Resources["memory"] = resource.MustParse("1Gi")
+	_, err = revertQuotaResources(kokiResources)
+	if err == nil {
+		t.Error("expected an error for the kube-native name memory")
+	}
+
+	_, err = convertQuotaResources(v1.ResourceList{"podz": resource.MustParse("1")})
+	if err == nil {
+		t.Error("expected an error for an unknown quota resource")
+	}
+}
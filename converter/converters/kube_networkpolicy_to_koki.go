@@ -0,0 +1,158 @@
+package converters
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koki/short/parser/expressions"
+	"github.com/koki/short/types"
+	serrors "github.com/koki/structurederrors"
+)
+
+func Convert_Kube_NetworkPolicy_to_Koki(kube *networkingv1.NetworkPolicy) (*types.NetworkPolicyWrapper, error) {
+	var err error
+	koki := &types.NetworkPolicy{}
+
+	koki.Name = kube.Name
+	koki.Namespace = kube.Namespace
+	koki.Version = kube.APIVersion
+	koki.Cluster = kube.ClusterName
+	koki.Labels = kube.Labels
+	koki.Annotations = kube.Annotations
+
+	koki.Selector, err = expressions.UnparseLabelSelector(&kube.Spec.PodSelector)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "NetworkPolicy.Spec.PodSelector")
+	}
+
+	koki.Ingress, err = convertNetworkPolicyIngressRules(kube.Spec.Ingress)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "NetworkPolicy.Spec.Ingress")
+	}
+
+	koki.Egress, err = convertNetworkPolicyEgressRules(kube.Spec.Egress)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "NetworkPolicy.Spec.Egress")
+	}
+
+	koki.PolicyTypes, err = convertPolicyTypes(kube.Spec.PolicyTypes)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "NetworkPolicy.Spec.PolicyTypes")
+	}
+
+	return &types.NetworkPolicyWrapper{
+		NetworkPolicy: *koki,
+	}, nil
+}
+
+func convertNetworkPolicyIngressRules(kubeRules []networkingv1.NetworkPolicyIngressRule) ([]types.NetworkPolicyIngressRule, error) {
+	if kubeRules == nil {
+		return nil, nil
+	}
+
+	var err error
+	kokiRules := make([]types.NetworkPolicyIngressRule, len(kubeRules))
+	for i, kubeRule := range kubeRules {
+		kokiRules[i].Ports = convertNetworkPolicyPorts(kubeRule.Ports)
+		kokiRules[i].From, err = convertNetworkPolicyPeers(kubeRule.From)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d].From", i)
+		}
+	}
+
+	return kokiRules, nil
+}
+
+func convertNetworkPolicyEgressRules(kubeRules []networkingv1.NetworkPolicyEgressRule) ([]types.NetworkPolicyEgressRule, error) {
+	if kubeRules == nil {
+		return nil, nil
+	}
+
+	var err error
+	kokiRules := make([]types.NetworkPolicyEgressRule, len(kubeRules))
+	for i, kubeRule := range kubeRules {
+		kokiRules[i].Ports = convertNetworkPolicyPorts(kubeRule.Ports)
+		kokiRules[i].To, err = convertNetworkPolicyPeers(kubeRule.To)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d].To", i)
+		}
+	}
+
+	return kokiRules, nil
+}
+
+func convertNetworkPolicyPorts(kubePorts []networkingv1.NetworkPolicyPort) []types.NetworkPolicyPort {
+	if kubePorts == nil {
+		return nil
+	}
+
+	kokiPorts := make([]types.NetworkPolicyPort, len(kubePorts))
+	for i, kubePort := range kubePorts {
+		kokiPorts[i].Port = kubePort.Port
+		if kubePort.Protocol != nil {
+			kokiPorts[i].Protocol = convertProtocol(*kubePort.Protocol)
+		}
+	}
+
+	return kokiPorts
+}
+
+func convertNetworkPolicyPeers(kubePeers []networkingv1.NetworkPolicyPeer) ([]types.NetworkPolicyPeer, error) {
+	if kubePeers == nil {
+		return nil, nil
+	}
+
+	var err error
+	kokiPeers := make([]types.NetworkPolicyPeer, len(kubePeers))
+	for i, kubePeer := range kubePeers {
+		kokiPeers[i].Pods, err = convertOptionalLabelSelector(kubePeer.PodSelector)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d].PodSelector", i)
+		}
+
+		kokiPeers[i].Namespaces, err = convertOptionalLabelSelector(kubePeer.NamespaceSelector)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "[%d].NamespaceSelector", i)
+		}
+
+		if kubePeer.IPBlock != nil {
+			kokiPeers[i].CIDR = kubePeer.IPBlock.CIDR
+			kokiPeers[i].Except = kubePeer.IPBlock.Except
+		}
+	}
+
+	return kokiPeers, nil
+}
+
+func convertOptionalLabelSelector(kubeSelector *metav1.LabelSelector) (*string, error) {
+	if kubeSelector == nil {
+		return nil, nil
+	}
+
+	kokiSelector, err := expressions.UnparseLabelSelector(kubeSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kokiSelector, nil
+}
+
+func convertPolicyTypes(kubeTypes []networkingv1.PolicyType) ([]types.PolicyType, error) {
+	if len(kubeTypes) == 0 {
+		return nil, nil
+	}
+
+	kokiTypes := make([]types.PolicyType, len(kubeTypes))
+	for i, kubeType := range kubeTypes {
+		switch kubeType {
+		case networkingv1.PolicyTypeIngress:
+			kokiTypes[i] = types.PolicyTypeIngress
+		case networkingv1.PolicyTypeEgress:
+			kokiTypes[i] = types.PolicyTypeEgress
+		default:
+			return nil, serrors.InvalidInstanceErrorf(kubeType, "[%d]", i)
+		}
+	}
+
+	return kokiTypes, nil
+}
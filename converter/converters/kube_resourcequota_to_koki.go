@@ -0,0 +1,145 @@
+package converters
+
+import (
+	"k8s.io/api/core/v1"
+
+	"github.com/koki/short/types"
+	serrors "github.com/koki/structurederrors"
+)
+
+func Convert_Kube_ResourceQuota_to_Koki(kube *v1.ResourceQuota) (*types.ResourceQuotaWrapper, error) {
+	var err error
+	koki := &types.ResourceQuota{}
+
+	koki.Name = kube.Name
+	koki.Namespace = kube.Namespace
+	koki.Version = kube.APIVersion
+	koki.Cluster = kube.ClusterName
+	koki.Labels = kube.Labels
+	koki.Annotations = kube.Annotations
+
+	koki.Hard, err = convertQuotaResources(kube.Spec.Hard)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "ResourceQuota.Spec.Hard")
+	}
+
+	koki.Scopes, err = convertResourceQuotaScopes(kube.Spec.Scopes)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "ResourceQuota.Spec.Scopes")
+	}
+
+	status := types.ResourceQuotaStatus{}
+	status.Hard, err = convertQuotaResources(kube.Status.Hard)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "ResourceQuota.Status.Hard")
+	}
+
+	status.Used, err = convertQuotaResources(kube.Status.Used)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "ResourceQuota.Status.Used")
+	}
+
+	if status.Hard != nil || status.Used != nil {
+		koki.Status = &status
+	}
+
+	return &types.ResourceQuotaWrapper{
+		ResourceQuota: *koki,
+	}, nil
+}
+
+func convertQuotaResourceName(name v1.ResourceName) (v1.ResourceName, error) {
+	if isQualifiedQuotaResource(name) {
+		return name, nil
+	}
+
+	for _, names := range quotaResourceNames {
+		if names[0] == name {
+			return names[1], nil
+		}
+	}
+
+	return "", serrors.InvalidValueErrorf(name, "unknown quota resource")
+}
+
+func convertQuotaResources(kubeResources v1.ResourceList) (*types.QuotaResources, error) {
+	if len(kubeResources) == 0 {
+		return nil, nil
+	}
+
+	kokiResources := &types.QuotaResources{}
+	requirements := v1.ResourceRequirements{
+		Limits:   v1.ResourceList{},
+		Requests: v1.ResourceList{},
+	}
+	others := v1.ResourceList{}
+	for name, q := range kubeResources {
+		switch name {
+		case v1.ResourceRequestsCPU:
+			requirements.Requests[v1.ResourceCPU] = q
+		case v1.ResourceLimitsCPU:
+			requirements.Limits[v1.ResourceCPU] = q
+		case v1.ResourceRequestsMemory:
+			requirements.Requests[v1.ResourceMemory] = q
+		case v1.ResourceLimitsMemory:
+			requirements.Limits[v1.ResourceMemory] = q
+		default:
+			others[name] = q
+		}
+	}
+
+	// A bare "cpu" or "memory" entry would collide with the min/max syntax, so keep the
+	// separate requests and limits names in that case.
+	for name, names := range quotaRequirementNames {
+		if _, ok := others[name]; !ok {
+			continue
+		}
+		if q, ok := requirements.Requests[name]; ok {
+			others[names[0]] = q
+			delete(requirements.Requests, name)
+		}
+		if q, ok := requirements.Limits[name]; ok {
+			others[names[1]] = q
+			delete(requirements.Limits, name)
+		}
+	}
+
+	kokiResources.CPU = convertCPU(requirements)
+	kokiResources.Mem = convertMem(requirements)
+	for name, q := range others {
+		kokiName, err := convertQuotaResourceName(name)
+		if err != nil {
+			return nil, err
+		}
+		if kokiResources.Resources == nil {
+			kokiResources.Resources = v1.ResourceList{}
+		}
+		kokiResources.Resources[kokiName] = q
+	}
+
+	return kokiResources, nil
+}
+
+func convertResourceQuotaScopes(kubeScopes []v1.ResourceQuotaScope) ([]types.ResourceQuotaScope, error) {
+	if len(kubeScopes) == 0 {
+		return nil, nil
+	}
+
+	kokiScopes := make([]types.ResourceQuotaScope, len(kubeScopes))
+	for i, kubeScope := range kubeScopes {
+		switch kubeScope {
+		case v1.ResourceQuotaScopeTerminating:
+			kokiScopes[i] = types.ResourceQuotaScopeTerminating
+		case v1.ResourceQuotaScopeNotTerminating:
+			kokiScopes[i] = types.ResourceQuotaScopeNotTerminating
+		case v1.ResourceQuotaScopeBestEffort:
+			kokiScopes[i] = types.ResourceQuotaScopeBestEffort
+		case v1.ResourceQuotaScopeNotBestEffort:
+			kokiScopes[i] = types.ResourceQuotaScopeNotBestEffort
+		default:
+			return nil, serrors.InvalidInstanceErrorf(kubeScope, "[%d]", i)
+		}
+	}
+
+	return kokiScopes, nil
+}
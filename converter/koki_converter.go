@@ -17,6 +17,7 @@ import (
 	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
 	"k8s.io/api/core/v1"
 	exts "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbac "k8s.io/api/rbac/v1"
 	schedulingv1alpha1 "k8s.io/api/scheduling/v1alpha1"
@@ -69,6 +70,8 @@ func DetectAndConvertFromKokiObj(kokiObj interface{}) (interface{}, error) {
 		return converters.Convert_Koki_LimitRange_to_Kube(kokiObj)
 	case *types.NamespaceWrapper:
 		return converters.Convert_Koki_Namespace_to_Kube_Namespace(kokiObj)
+	case *types.NetworkPolicyWrapper:
+		return converters.Convert_Koki_NetworkPolicy_to_Kube(kokiObj)
 	case *types.PersistentVolumeClaimWrapper:
 		return converters.Convert_Koki_PVC_to_Kube_PVC(kokiObj)
 	case *types.PersistentVolumeWrapper:
@@ -89,6 +92,8 @@ func DetectAndConvertFromKokiObj(kokiObj interface{}) (interface{}, error) {
 		return converters.Convert_Koki_ReplicationController_to_Kube_v1_ReplicationController(kokiObj)
 	case *types.ReplicaSetWrapper:
 		return converters.Convert_Koki_ReplicaSet_to_Kube_ReplicaSet(kokiObj)
+	case *types.ResourceQuotaWrapper:
+		return converters.Convert_Koki_ResourceQuota_to_Kube(kokiObj)
 	case *types.RoleWrapper:
 		return converters.Convert_Koki_Role_to_Kube(kokiObj)
 	case *types.RoleBindingWrapper:
@@ -154,6 +159,8 @@ func DetectAndConvertFromKubeObj(kubeObj runtime.Object) (interface{}, error) {
 		return converters.Convert_Kube_LimitRange_to_Koki(kubeObj)
 	case *v1.Namespace:
 		return converters.Convert_Kube_Namespace_to_Koki_Namespace(kubeObj)
+	case *networkingv1.NetworkPolicy:
+		return converters.Convert_Kube_NetworkPolicy_to_Koki(kubeObj)
 	case *v1.PersistentVolume:
 		return converters.Convert_Kube_v1_PersistentVolume_to_Koki_PersistentVolume(kubeObj)
 	case *v1.PersistentVolumeClaim:
@@ -174,6 +181,8 @@ func DetectAndConvertFromKubeObj(kubeObj runtime.Object) (interface{}, error) {
 		return converters.Convert_Kube_v1_ReplicationController_to_Koki_ReplicationController(kubeObj)
 	case *appsv1beta2.ReplicaSet, *exts.ReplicaSet:
 		return converters.Convert_Kube_ReplicaSet_to_Koki_ReplicaSet(kubeObj)
+	case *v1.ResourceQuota:
+		return converters.Convert_Kube_ResourceQuota_to_Koki(kubeObj)
 	case *rbac.Role:
 		return converters.Convert_Kube_Role_to_Koki(kubeObj)
 	case *rbac.RoleBinding:
@@ -0,0 +1,173 @@
+package imports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/golang/glog"
+
+	"github.com/koki/json"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// moduleCacheKey identifies the contents of a module file.
+type moduleCacheKey struct {
+	Path string
+	Hash string
+}
+
+type cachedModules struct {
+	modules []Module
+
+	// imports are the keys of the modules imported by modules when they were parsed.
+	imports []moduleCacheKey
+}
+
+// Reload makes the next Parse read each module file again, e.g. after files have changed.
+// Modules whose contents (and imports) are unchanged are still reused.
+func (c *EvalContext) Reload() {
+	c.loaded = nil
+}
+
+// parseCached reads and parses the module at path. Each file is only read once until the
+// next Reload, and a module is only parsed again if its contents (or their positions in the file)
+// or imports changed.
+// The cached modules must not be modified. (See clone.)
+func (c *EvalContext) parseCached(path string) ([]Module, error) {
+	if c.noCache {
+		objs, maps, err := c.readModule(path)
+		if err != nil {
+			return nil, err
+		}
+		return c.parseModules(path, objs, maps)
+	}
+
+	if key, ok := c.loaded[moduleKey(path)]; ok {
+		if cached, ok := c.modules[key]; ok {
+			return cached.modules, nil
+		}
+	}
+
+	objs, maps, err := c.readModule(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := moduleCacheKeyFor(path, objs, maps)
+	if err != nil {
+		// Parsing is still possible, it just won't be cached.
+		glog.V(3).Infof("not caching module (%s): %s", path, err.Error())
+		return c.parseModules(path, objs, maps)
+	}
+
+	if cached, ok := c.modules[key]; ok && c.importsUnchanged(cached) {
+		c.markLoaded(key)
+		return cached.modules, nil
+	}
+
+	modules, err := c.parseModules(path, objs, maps)
+	if err != nil {
+		return nil, err
+	}
+
+	importKeys, ok := c.importKeys(modules)
+	if !ok {
+		// An import couldn't be cached, so this module can't be either.
+		return modules, nil
+	}
+
+	if c.modules == nil {
+		c.modules = map[moduleCacheKey]cachedModules{}
+	}
+	c.modules[key] = cachedModules{modules: modules, imports: importKeys}
+	c.markLoaded(key)
+
+	return modules, nil
+}
+
+func (c *EvalContext) readModule(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
+	objs, maps, err := c.ReadFromPath(path)
+	if err != nil {
+		return nil, nil, serrors.InvalidValueContextErrorf(err, path, "reading module")
+	}
+
+	return objs, maps, nil
+}
+
+func (c *EvalContext) markLoaded(key moduleCacheKey) {
+	if c.loaded == nil {
+		c.loaded = map[string]moduleCacheKey{}
+	}
+	c.loaded[key.Path] = key
+}
+
+// importsUnchanged loads each of the cached modules' imports (reusing them
+// from the cache where possible) and checks that they're the ones the cached modules were parsed with.
+func (c *EvalContext) importsUnchanged(cached cachedModules) bool {
+	for _, importKey := range cached.imports {
+		_, err := c.Parse(importKey.Path)
+		if err != nil {
+			return false
+		}
+		if key, ok := c.loaded[importKey.Path]; !ok || key != importKey {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *EvalContext) importKeys(modules []Module) ([]moduleCacheKey, bool) {
+	keys := []moduleCacheKey{}
+	for _, module := range modules {
+		for _, imprt := range module.Imports {
+			if isLocalImport(module.Path, imprt) {
+				continue
+			}
+			key, ok := c.loaded[moduleKey(imprt.Path)]
+			if !ok {
+				return nil, false
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, true
+}
+
+// moduleCacheKeyFor hashes the contents of a module file, and where they are in the file,
+// so the modules' SourceMaps locate errors in the file as it was read this time.
+func moduleCacheKeyFor(path string, objs []map[string]interface{}, maps parser.SourceMaps) (moduleCacheKey, error) {
+	positions := make([]map[string]parser.Position, len(maps))
+	for i, sourceMap := range maps {
+		positions[i] = sourceMap.Positions()
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"objs": objs, "positions": positions})
+	if err != nil {
+		return moduleCacheKey{}, serrors.InvalidValueContextErrorf(err, objs, "hashing module contents")
+	}
+
+	hash := sha256.Sum256(b)
+	return moduleCacheKey{
+		Path: moduleKey(path),
+		Hash: hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// clone copies the parts of a module that evaluation changes, so that a
+// shared module can be evaluated with different params each time it's imported.
+// Imported modules are cloned when their Import is evaluated.
+func (m *Module) clone() *Module {
+	clone := *m
+	if m.Imports != nil {
+		clone.Imports = make([]*Import, len(m.Imports))
+		for i, imprt := range m.Imports {
+			imprtClone := *imprt
+			clone.Imports[i] = &imprtClone
+		}
+	}
+
+	return &clone
+}
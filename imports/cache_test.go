@@ -0,0 +1,194 @@
+package imports
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koki/short/parser"
+)
+
+// countingEvalContext reads modules (with sections separated by ---) from sources and counts how many times each one is read.
+func countingEvalContext(sources map[string]string, reads map[string]int) *EvalContext {
+	return &EvalContext{
+		RawToTyped: func(raw interface{}) (interface{}, error) {
+			return raw, nil
+		},
+		ResolveImportPath: func(rootPath string, importPath string) (string, error) {
+			return importPath, nil
+		},
+		ReadFromPath: func(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
+			contents, ok := sources[path]
+			if !ok {
+				return nil, nil, fmt.Errorf("no module (%s)", path)
+			}
+			reads[path]++
+
+			return parser.ParseSource(path, []byte(contents))
+		},
+	}
+}
+
+// diamondModules builds a root module that imports width modules, which all import the same sidecar module.
+func diamondModules(width int) map[string]string {
+	sources := map[string]string{
+		"sidecar": `
+imports:
+- labels: labels
+params:
+- name: the container name
+container:
+  name: ${name}
+  image: nginx
+  labels: ${labels}
+`,
+		"labels": `
+labels:
+  app: web
+`,
+	}
+
+	root := []string{"imports:"}
+	for i := 0; i < width; i++ {
+		pod := fmt.Sprintf("pod%d", i)
+		root = append(root, fmt.Sprintf("- %s: %s", pod, pod))
+		sources[pod] = fmt.Sprintf(`
+imports:
+- sidecar: sidecar
+  params:
+    name: %s
+pod:
+  name: %s
+  containers:
+  - ${sidecar}
+`, pod, pod)
+	}
+	root = append(root, "pods:")
+	for i := 0; i < width; i++ {
+		root = append(root, fmt.Sprintf("- ${pod%d}", i))
+	}
+	sources["root"] = strings.Join(root, "\n")
+
+	return sources
+}
+
+func TestModuleCache(t *testing.T) {
+	sources := diamondModules(3)
+	reads := map[string]int{}
+	evalContext := countingEvalContext(sources, reads)
+
+	for round := 0; round < 2; round++ {
+		modules, err := evalContext.Parse("root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		module := &modules[0]
+		err = evalContext.EvaluateModule(module, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Each pod gets its own copy of the shared sidecar module.
+		pods := module.Export.Raw["pods"].([]interface{})
+		for i, pod := range pods {
+			containers := pod.(map[string]interface{})["containers"].([]interface{})
+			container := containers[0].(map[string]interface{})
+			expected := map[string]interface{}{
+				"name":   fmt.Sprintf("pod%d", i),
+				"image":  "nginx",
+				"labels": map[string]interface{}{"app": "web"},
+			}
+			if !reflect.DeepEqual(container, expected) {
+				t.Errorf("round %d, pod%d: expected container %v, got %v", round, i, expected, container)
+			}
+		}
+	}
+
+	// Each file is read once, even by the second root module.
+	for path := range sources {
+		if reads[path] != 1 {
+			t.Errorf("expected (%s) to be read once, got %d", path, reads[path])
+		}
+	}
+
+	// Changed files are parsed again after a Reload.
+	sources["labels"] = `
+labels:
+  app: api
+`
+	evalContext.Reload()
+	modules, err := evalContext.Parse("sidecar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := &modules[0]
+	err = evalContext.EvaluateModule(module, map[string]interface{}{"name": "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := module.Export.Raw["container"].(map[string]interface{})["labels"]
+	if !reflect.DeepEqual(labels, map[string]interface{}{"app": "api"}) {
+		t.Errorf("expected labels from the changed module, got %v", labels)
+	}
+
+	// Modules that only moved within their file are parsed again, so errors point at their new positions.
+	sources["labels"] = "# moved\n" + sources["labels"]
+	evalContext.Reload()
+	modules, err = evalContext.Parse("labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := modules[0].SourceMap.Lookup("labels.app"); pos.Line != 4 {
+		t.Errorf("expected labels.app at line 4 of the moved module, got %s", pos)
+	}
+}
+
+// Without the cache, each of the 50 pods reads and parses the sidecar and labels modules again.
+// With it, each file is read once per context, so a shared context reads nothing after the first op.
+func BenchmarkDiamondImports(b *testing.B) {
+	sources := diamondModules(50)
+	b.Run("no cache", func(b *testing.B) {
+		reads := map[string]int{}
+		for i := 0; i < b.N; i++ {
+			evalContext := countingEvalContext(sources, reads)
+			evalContext.noCache = true
+			benchmarkEvaluate(b, evalContext)
+		}
+		reportReads(b, reads)
+	})
+	b.Run("new context", func(b *testing.B) {
+		reads := map[string]int{}
+		for i := 0; i < b.N; i++ {
+			benchmarkEvaluate(b, countingEvalContext(sources, reads))
+		}
+		reportReads(b, reads)
+	})
+	b.Run("shared context", func(b *testing.B) {
+		reads := map[string]int{}
+		evalContext := countingEvalContext(sources, reads)
+		for i := 0; i < b.N; i++ {
+			benchmarkEvaluate(b, evalContext)
+		}
+		reportReads(b, reads)
+	})
+}
+
+func reportReads(b *testing.B, reads map[string]int) {
+	total := 0
+	for _, count := range reads {
+		total += count
+	}
+	b.ReportMetric(float64(total)/float64(b.N), "reads/op")
+}
+
+func benchmarkEvaluate(b *testing.B, evalContext *EvalContext) {
+	modules, err := evalContext.Parse("root")
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = evalContext.EvaluateModule(&modules[0], nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+}
@@ -10,7 +10,20 @@ func TrimToDepth(module *Module, depth int) bool {
 	}
 
 	for _, imprt := range module.Imports {
-		trimmed = trimmed || TrimToDepth(imprt.Module, depth-1)
+		// Imported modules may be shared, so trim a copy.
+		if imprt.Module != nil {
+			imprt.Module = imprt.Module.clone()
+			trimmed = TrimToDepth(imprt.Module, depth-1) || trimmed
+		}
+
+		modules := make([]*Module, len(imprt.Modules))
+		for i, module := range imprt.Modules {
+			modules[i] = module.clone()
+			trimmed = TrimToDepth(modules[i], depth-1) || trimmed
+		}
+		if imprt.Modules != nil {
+			imprt.Modules = modules
+		}
 	}
 
 	return trimmed
@@ -1,7 +1,9 @@
 package imports
 
 import (
+	"github.com/koki/short/parser"
 	"github.com/koki/short/template"
+	serrors "github.com/koki/structurederrors"
 )
 
 /*
@@ -15,8 +17,9 @@ An import is evaluated by:
   2. Apply its Params to its Module using the other Imports.
 
 A module is evaluated by:
-  1. Build its Result by filling its Raw template from the Module.Raw of its Imports.
-  2. Parse its TypedResult
+  1. Validate its params against their definitions.
+  2. Build its Result by filling its Raw template from the Module.Raw of its Imports.
+  3. Parse its TypedResult
 
 */
 
@@ -33,9 +36,29 @@ func (c *EvalContext) EvaluateImport(inModule *Module, inModuleParams map[string
 	imprt.Params = params
 
 	// Evaluate the Module with these parameters.
-	err = c.EvaluateModule(imprt.Module, imprt.Params)
+	// The Module may be shared with other Imports, so evaluate a copy.
+	if imprt.Section == allSections {
+		modules := make([]*Module, len(imprt.Modules))
+		for i, module := range imprt.Modules {
+			modules[i] = module.clone()
+			// Each section fills in its own defaults, so each gets its own params.
+			err = c.EvaluateModule(modules[i], MergeParams(imprt.Params, nil))
+			if err != nil {
+				return serrors.ContextualizeErrorf(err, "import (%s) in module (%s)", imprt.Name, inModule.Path)
+			}
+		}
+		imprt.Modules = modules
+	} else {
+		imprt.Module = imprt.Module.clone()
+		err = c.EvaluateModule(imprt.Module, imprt.Params)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "import (%s) in module (%s)", imprt.Name, inModule.Path)
+		}
+	}
+
+	err = c.applyOverlays(inModule, inModuleParams, imprt)
 	if err != nil {
-		return err
+		return parser.LocateError(serrors.ContextualizeErrorf(err, "overriding import (%s)", imprt.Name), inModule.SourceMap)
 	}
 
 	imprt.IsEvaluated = true
@@ -61,7 +84,13 @@ func (c *EvalContext) EvaluateModule(module *Module, params map[string]interface
 		}
 	}
 
-	err := c.EvaluateExport(module, params, &module.Export)
+	// Check the params before they're substituted into the module.
+	err := c.validateParams(module, params)
+	if err != nil {
+		return err
+	}
+
+	err = c.EvaluateExport(module, params, &module.Export)
 	if err != nil {
 		return err
 	}
@@ -76,14 +105,14 @@ func (c *EvalContext) EvaluateExport(module *Module, params map[string]interface
 	// Evaluation is lazy so we don't have to determine ahead-of-time which order to evaluate the imports in.
 	raw, err := template.ReplaceMap(export.Raw, c.ResolverForModule(module, params))
 	if err != nil {
-		return err
+		return parser.LocateError(err, module.SourceMap)
 	}
 	export.Raw = raw
 
 	// All template substitutions should be complete. Evaluate the result.
 	export.TypedResult, err = c.RawToTyped(export.Raw)
 	if err != nil {
-		export.TypedResult = err
+		export.TypedResult = parser.LocateError(err, module.SourceMap)
 	}
 
 	return nil
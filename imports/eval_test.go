@@ -7,6 +7,7 @@ import (
 
 	"github.com/kr/pretty"
 
+	"github.com/koki/short/parser"
 	"github.com/koki/short/yaml"
 )
 
@@ -60,6 +61,34 @@ thing:
   doot:
   - what: hello
   - not: this
+`,
+	"module8": `
+imports:
+- import0: module1
+  params:
+    param0: first
+- import1: module1
+  params:
+    param0: second
+value:
+- ${import0}
+- ${import1}
+`,
+	"module9": `
+imports:
+- import7: module7
+- import5: module5
+params:
+- verbose: a flag
+  default: false
+value:
+  items:
+    for_each: ${import7.doot}
+    do: ${item}
+  extra:
+    if: ${verbose}
+    then: ${import5}
+    else: ${import7.blah}
 `,
 }
 
@@ -89,6 +118,21 @@ var evalResults = map[string]interface{}{
 			"something",
 		},
 	},
+	"module8": map[string]interface{}{
+		"value": []interface{}{
+			"first",
+			"second",
+		},
+	},
+	"module9": map[string]interface{}{
+		"value": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"what": "hello"},
+				map[string]interface{}{"not": "this"},
+			},
+			"extra": "bleh",
+		},
+	},
 }
 
 func getFullEvalContext(t *testing.T) *EvalContext {
@@ -99,16 +143,16 @@ func getFullEvalContext(t *testing.T) *EvalContext {
 		ResolveImportPath: func(rootPath string, importPath string) (string, error) {
 			return importPath, nil
 		},
-		ReadFromPath: func(path string) ([]map[string]interface{}, error) {
+		ReadFromPath: func(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
 			if contents, ok := evalModules[path]; ok {
 				obj := map[string]interface{}{}
 				err := yaml.Unmarshal([]byte(contents), &obj)
 				if err != nil {
 					t.Fatal(err)
 				}
-				return []map[string]interface{}{obj}, nil
+				return []map[string]interface{}{obj}, nil, nil
 			}
-			return nil, fmt.Errorf("no module (%s)", path)
+			return nil, nil, fmt.Errorf("no module (%s)", path)
 		},
 	}
 }
@@ -121,6 +165,8 @@ func TestEval(t *testing.T) {
 	doTestEval("module4", t, false)
 	doTestEval("module5", t, false)
 	doTestEval("module6", t, false)
+	doTestEval("module8", t, false)
+	doTestEval("module9", t, false)
 }
 
 func doTestEval(modulePath string, t *testing.T, expectEvalError bool) {
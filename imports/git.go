@@ -0,0 +1,246 @@
+package imports
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+const gitScheme = "git+"
+
+var gitCommitRegexp = regexp.MustCompile(`^[0-9a-f]{40,64}$`)
+
+// GitSource fetches modules from git repositories, e.g.
+//
+//	git+file:///path/to/repo//modules/app.yaml?ref=v1.2
+//	git+https://example.com/org/repo.git//modules/app.yaml?ref=main
+//
+// The repository URL comes before the "//", and the module's path in the repository after it.
+// ref is a branch, tag, or commit (default: HEAD). Each ref is resolved to a commit.
+type GitSource struct {
+	// CacheDir is where the repositories are cloned. It must be set.
+	CacheDir string
+
+	// fetched repositories are up to date, so they aren't fetched again.
+	fetched map[string]bool
+}
+
+// gitModule is a parsed git module path.
+type gitModule struct {
+	Repo string
+	Path string
+	Ref  string
+}
+
+// IsGitPath is true if path is a module in a git repository (see GitSource).
+func IsGitPath(path string) bool {
+	return strings.HasPrefix(path, gitScheme)
+}
+
+func parseGitPath(gitPath string) (*gitModule, error) {
+	u, err := url.Parse(strings.TrimPrefix(gitPath, gitScheme))
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, gitPath, "parsing git module path")
+	}
+
+	i := strings.Index(u.Path, "//")
+	if i < 0 || i+2 == len(u.Path) {
+		return nil, serrors.InvalidValueErrorf(gitPath, "expected the module's path in the repository after //")
+	}
+
+	module := &gitModule{
+		Path: u.Path[i+2:],
+		Ref:  u.Query().Get("ref"),
+	}
+	if len(module.Ref) == 0 {
+		module.Ref = "HEAD"
+	}
+	if strings.HasPrefix(module.Ref, "-") {
+		return nil, serrors.InvalidValueErrorf(module.Ref, "invalid git ref in (%s)", gitPath)
+	}
+
+	u.Path = u.Path[:i]
+	u.RawQuery = ""
+	module.Repo = u.String()
+
+	return module, nil
+}
+
+// localRepo returns the path of the repository if it's on the local filesystem,
+// e.g. file:///path/to/repo, /path/to/repo, or ../repo.
+func (m *gitModule) localRepo() (string, bool) {
+	u, err := url.Parse(m.Repo)
+	if err != nil || (len(u.Scheme) > 0 && u.Scheme != "file") {
+		return "", false
+	}
+
+	return u.Path, true
+}
+
+// resolveGitRepo resolves a relative local repository in importPath against the
+// module at rootPath, like a local import. (git would resolve it against the working directory.)
+func resolveGitRepo(rootPath, importPath string) (string, error) {
+	module, err := parseGitPath(importPath)
+	if err != nil {
+		return "", err
+	}
+
+	repo, isLocal := module.localRepo()
+	if !isLocal || filepath.IsAbs(repo) {
+		return importPath, nil
+	}
+	if parser.IsURL(rootPath) || IsGitPath(rootPath) {
+		return "", serrors.InvalidValueErrorf(importPath, "remote module (%s) can't import a git repository on the local filesystem", rootPath)
+	}
+
+	module.Repo = filepath.ToSlash(filepath.Join(filepath.Dir(rootPath), filepath.FromSlash(repo)))
+	return module.String(), nil
+}
+
+func (m *gitModule) String() string {
+	return gitScheme + m.Repo + "//" + m.Path + "?ref=" + url.QueryEscape(m.Ref)
+}
+
+func (s *GitSource) Matches(path string) bool {
+	return IsGitPath(path)
+}
+
+func (s *GitSource) Resolve(gitPath string) (string, error) {
+	module, err := parseGitPath(gitPath)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := s.clone(module.Repo, true)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := git(dir, "rev-parse", "--verify", "--quiet", module.Ref+"^{commit}")
+	if err != nil {
+		return "", serrors.InvalidValueContextErrorf(err, module.Ref, "resolving ref in git repository %s", module.Repo)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+func (s *GitSource) Fetch(gitPath, commit string) ([]byte, error) {
+	module, err := parseGitPath(gitPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !gitCommitRegexp.MatchString(commit) {
+		return nil, serrors.InvalidValueErrorf(commit, "expected a git commit for (%s)", gitPath)
+	}
+
+	dir, err := s.clone(module.Repo, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := git(dir, "cat-file", "-e", commit+"^{commit}"); err != nil {
+		// The commit is newer than the clone.
+		dir, err = s.clone(module.Repo, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := git(dir, "show", commit+":"+module.Path)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, module.Path, "reading file at commit %s in git repository %s", commit, module.Repo)
+	}
+
+	return []byte(out), nil
+}
+
+// ResolveImport resolves relative imports to the same repository and ref.
+func (s *GitSource) ResolveImport(rootPath, importPath string) (string, error) {
+	if IsGitPath(importPath) {
+		return importPath, nil
+	}
+
+	module, err := parseGitPath(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	importPath = filepath.ToSlash(importPath)
+	if path.IsAbs(importPath) {
+		return "", serrors.InvalidValueErrorf(importPath, "imports in git module (%s) must be relative", rootPath)
+	}
+
+	imported := *module
+	imported.Path = path.Join(path.Dir(module.Path), importPath)
+	if imported.Path == ".." || strings.HasPrefix(imported.Path, "../") {
+		return "", serrors.InvalidValueErrorf(importPath, "import is outside of the git repository for module (%s)", rootPath)
+	}
+
+	return imported.String(), nil
+}
+
+// clone makes a bare mirror of repo in the cache, or updates the existing one if fetch is true.
+func (s *GitSource) clone(repo string, fetch bool) (string, error) {
+	dir := filepath.Join(s.CacheDir, "git", sha256Digest([]byte(repo))[:16])
+	if _, err := os.Stat(dir); err != nil {
+		err = os.MkdirAll(filepath.Dir(dir), 0755)
+		if err != nil {
+			return "", serrors.ContextualizeErrorf(err, "creating git cache")
+		}
+
+		glog.V(3).Infof("cloning git repository %s", repo)
+		_, err = git("", "clone", "--quiet", "--mirror", "--", repo, dir)
+		if err != nil {
+			return "", serrors.InvalidValueContextErrorf(err, repo, "cloning git repository")
+		}
+		s.markFetched(repo)
+		return dir, nil
+	}
+
+	if fetch && !s.fetched[repo] {
+		glog.V(3).Infof("fetching git repository %s", repo)
+		_, err := git(dir, "fetch", "--quiet", "--prune")
+		if err != nil {
+			return "", serrors.InvalidValueContextErrorf(err, repo, "fetching git repository")
+		}
+		s.markFetched(repo)
+	}
+
+	return dir, nil
+}
+
+func (s *GitSource) markFetched(repo string) {
+	if s.fetched == nil {
+		s.fetched = map[string]bool{}
+	}
+	s.fetched[repo] = true
+}
+
+// git runs a git command (in the repository at dir, if it's not empty) and returns its output.
+func git(dir string, args ...string) (string, error) {
+	if len(dir) > 0 {
+		args = append([]string{"--git-dir", dir}, args...)
+	}
+
+	cmd := exec.Command("git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", serrors.ContextualizeErrorf(err, "git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
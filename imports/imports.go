@@ -1,7 +1,12 @@
 package imports
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/golang/glog"
 
@@ -10,23 +15,74 @@ import (
 )
 
 func (c *EvalContext) Parse(rootPath string) ([]Module, error) {
-	objs, err := c.ReadFromPath(rootPath)
+	err := c.startParsing(rootPath)
 	if err != nil {
-		return nil, serrors.InvalidValueContextErrorf(err, rootPath, "reading module")
+		return nil, err
+	}
+	defer c.finishParsing()
+
+	err = c.checkModuleRoot(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := c.parseCached(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers evaluate the modules, so they get their own copies.
+	modules := make([]Module, len(cached))
+	for i := range cached {
+		modules[i] = *cached[i].clone()
 	}
 
+	return modules, nil
+}
+
+// ParseObjects parses modules that were read from somewhere other than a file, e.g. stdin.
+// Their imports are resolved relative to rootPath, like a module read from the file at rootPath.
+// Nil objs are skipped (e.g. documents that aren't koki modules), but each module keeps its
+// document index in objs. Errors are located with maps, the SourceMaps of objs (or nil).
+func (c *EvalContext) ParseObjects(rootPath string, objs []map[string]interface{}, maps parser.SourceMaps) ([]Module, error) {
+	err := c.startParsing(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	defer c.finishParsing()
+
+	err = c.checkModuleRoot(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseModules(rootPath, objs, maps)
+}
+
+func (c *EvalContext) parseModules(rootPath string, objs []map[string]interface{}, maps parser.SourceMaps) ([]Module, error) {
 	if len(objs) > 1 {
-		glog.V(1).Infof("(%s) has multiple sections. only the first section can be imported by other modules.", rootPath)
+		glog.V(1).Infof("(%s) has multiple sections. imports use the first section unless they select another (e.g. %s#1).", rootPath, rootPath)
 	}
 
-	modules := make([]Module, len(objs))
+	modules := make([]Module, 0, len(objs))
 	for i, obj := range objs {
+		if obj == nil {
+			continue
+		}
+
 		module, err := c.ParseComponent(rootPath, obj)
 		if err != nil {
-			return nil, err
+			return nil, parser.LocateError(err, maps.Document(i))
 		}
 
-		modules[i] = *module
+		module.Document = i
+		module.SourceMap = maps.Document(i)
+		modules = append(modules, *module)
+	}
+
+	err := resolveLocalImports(rootPath, modules)
+	if err != nil {
+		return nil, err
 	}
 
 	return modules, nil
@@ -87,6 +143,8 @@ func parseParamDefs(rootPath string, obj map[string]interface{}) (map[string]Par
 	return params, hasParamsKey, nil
 }
 
+// parseParamDef parses a param definition: a name, a map of name to description (with settings
+// like default next to it), or a map with the name under param (for names that are also settings).
 func parseParamDef(obj interface{}) (string, ParamDef, error) {
 	def := ParamDef{}
 	switch obj := obj.(type) {
@@ -94,26 +152,90 @@ func parseParamDef(obj interface{}) (string, ParamDef, error) {
 		return obj, def, nil
 	case map[string]interface{}:
 		name := ""
+		_, hasParamKey := obj["param"]
 		for key, val := range obj {
-			switch key {
-			case "default":
-				def.Default = val
-			default:
-				name = key
-				if description, ok := val.(string); ok {
-					def.Description = description
-				} else {
-					return name, def, serrors.InvalidValueForTypeErrorf(val, def, "interpreted key (%s) as param name. expected string value (for param description).", key)
+			var ok bool
+			switch {
+			case len(obj) == 1:
+				// Just a name and its description, even if the name is also a setting.
+			case hasParamKey && key == "param":
+				name, ok = val.(string)
+				if !ok || len(name) == 0 {
+					return key, def, serrors.InvalidValueForTypeErrorf(val, def, "expected a param name")
+				}
+				continue
+			case hasParamKey && key == "description":
+				def.Description, ok = val.(string)
+				if !ok {
+					return key, def, serrors.InvalidValueForTypeErrorf(val, def, "expected a string description")
+				}
+				continue
+			case paramSettings[key]:
+				err := parseParamSetting(&def, key, val)
+				if err != nil {
+					return key, def, err
 				}
+				continue
+			case hasParamKey:
+				return key, def, serrors.InvalidValueForTypeErrorf(val, def, "unexpected key (%s) in a param named by (param)", key)
+			}
+
+			name = key
+			if description, ok := val.(string); ok {
+				def.Description = description
+			} else {
+				return name, def, serrors.InvalidValueForTypeErrorf(val, def, "interpreted key (%s) as param name. expected string value (for param description).", key)
 			}
 		}
 
+		if len(name) == 0 {
+			return name, def, serrors.InvalidValueForTypeErrorf(obj, def, "expected a param name")
+		}
+
 		return name, def, nil
 	default:
 		return "", def, serrors.InvalidValueForTypeErrorf(obj, def, "expected string or map")
 	}
 }
 
+// paramSettings are the keys of a param definition that describe its values.
+var paramSettings = map[string]bool{
+	"default":  true,
+	"type":     true,
+	"required": true,
+	"enum":     true,
+	"pattern":  true,
+}
+
+func parseParamSetting(def *ParamDef, key string, val interface{}) error {
+	var ok bool
+	switch key {
+	case "default":
+		def.Default = val
+		ok = true
+	case "type":
+		def.Type, ok = val.(string)
+	case "required":
+		def.Required, ok = val.(bool)
+	case "enum":
+		def.Enum, ok = val.([]interface{})
+	case "pattern":
+		def.Pattern, ok = val.(string)
+		if ok {
+			_, err := regexp.Compile(def.Pattern)
+			if err != nil {
+				return serrors.InvalidValueContextErrorf(err, val, "invalid pattern")
+			}
+		}
+	}
+
+	if !ok {
+		return serrors.InvalidValueForTypeErrorf(val, def, "unexpected value for (%s)", key)
+	}
+
+	return nil
+}
+
 func (c *EvalContext) parseImports(rootPath string, obj map[string]interface{}) ([]*Import, bool, error) {
 	hasImportsKey := false
 	imports := []*Import{}
@@ -148,21 +270,31 @@ func (c *EvalContext) parseImport(rootPath string, imprt map[string]interface{})
 	if len(imprt) == 0 {
 		return nil, serrors.InvalidInstanceErrorf(imprt, "empty import declaration")
 	}
-	if len(imprt) > 2 {
-		return nil, serrors.InvalidInstanceErrorf(imprt, "import declaration should have at most params and name:path")
-	}
 
 	imp := &Import{}
 	for key, val := range imprt {
-		if key == "params" {
+		switch key {
+		case "params":
 			if params, ok := val.(map[string]interface{}); ok {
 				imp.Params = params
 			} else {
 				return nil, serrors.InvalidInstanceErrorf(imprt, "params should be a dictionary")
 			}
-		} else {
+		case "overrides":
+			imp.Overrides = val
+		case "patch":
+			if patch, ok := val.([]interface{}); ok {
+				imp.Patch = patch
+			} else {
+				return nil, serrors.InvalidInstanceErrorf(imprt, "patch should be a list of operations")
+			}
+		default:
+			if len(imp.Name) > 0 {
+				return nil, serrors.InvalidInstanceErrorf(imprt, "import declaration should have at most params, overrides, patch, and name:path")
+			}
 			imp.Name = key
 			if importPath, ok := val.(string); ok {
+				importPath, imp.Section = SplitImportPath(importPath)
 				imp.Path, err = c.ResolveImportPath(rootPath, importPath)
 				if err != nil {
 					return nil, serrors.InvalidValueErrorf(importPath, "couldn't resolve 'absolute' path for import (%s) in module (%s)", importPath, rootPath)
@@ -176,16 +308,92 @@ func (c *EvalContext) parseImport(rootPath string, imprt map[string]interface{})
 	if len(imp.Name) == 0 {
 		return nil, serrors.InvalidInstanceErrorf(imprt, "expected import name and path")
 	}
+	if imp.Section == allSections && (imp.Overrides != nil || len(imp.Patch) > 0) {
+		return nil, serrors.InvalidInstanceErrorf(imprt, "overrides and patch can only be used when importing a single section")
+	}
+
+	if c.isParsingSectionsOf(rootPath, imp.Path) {
+		// Sections of the same file are resolved once they're all parsed. (See resolveLocalImports.)
+		return imp, nil
+	}
+
+	if len(c.parsing) > 0 {
+		c.parsing[len(c.parsing)-1].ImportName = imp.Name
+	}
 
 	importModules, err := c.Parse(imp.Path)
 	if err != nil {
 		return nil, err
 	}
-	imp.Module = &importModules[0]
+	sections, err := selectSections(imp.Path, imp.Section, importModules)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "import (%s)", imp.Name)
+	}
+	if imp.Section == allSections {
+		imp.Modules = sections
+	} else {
+		imp.Module = sections[0]
+	}
 
 	return imp, nil
 }
 
+// ImportCycleError is returned when a module imports itself, directly or transitively.
+type ImportCycleError struct {
+	// Chain starts and ends with the same module.
+	// Each link's ImportName is the name it imports the next module by.
+	Chain []importLink
+}
+
+func (e *ImportCycleError) Error() string {
+	paths := make([]string, len(e.Chain))
+	names := []string{}
+	for i, link := range e.Chain {
+		paths[i] = link.Path
+		if i < len(e.Chain)-1 {
+			names = append(names, link.ImportName)
+		}
+	}
+
+	return fmt.Sprintf("import cycle: %s (import names: %s)", strings.Join(paths, " -> "), strings.Join(names, ", "))
+}
+
+func (c *EvalContext) startParsing(path string) error {
+	key := moduleKey(path)
+	for i, link := range c.parsing {
+		if moduleKey(link.Path) == key {
+			chain := append([]importLink{}, c.parsing[i:]...)
+			chain = append(chain, importLink{Path: path})
+			return &ImportCycleError{Chain: chain}
+		}
+	}
+
+	c.parsing = append(c.parsing, importLink{Path: path})
+	return nil
+}
+
+// isParsingSectionsOf checks whether importPath is the module file at rootPath, and that file is the one being parsed.
+func (c *EvalContext) isParsingSectionsOf(rootPath, importPath string) bool {
+	if len(c.parsing) == 0 || moduleKey(importPath) != moduleKey(rootPath) {
+		return false
+	}
+
+	return moduleKey(c.parsing[len(c.parsing)-1].Path) == moduleKey(rootPath)
+}
+
+func (c *EvalContext) finishParsing() {
+	c.parsing = c.parsing[:len(c.parsing)-1]
+}
+
+// moduleKey normalizes a module path so that equivalent paths compare equal.
+func moduleKey(path string) string {
+	if parser.IsURL(path) || IsGitPath(path) {
+		return path
+	}
+
+	return filepath.Clean(path)
+}
+
 func ResolveImportLocalPath(rootPath string, importPath string) (string, error) {
 	if len(rootPath) > 0 {
 		dirPath, _ := filepath.Split(rootPath)
@@ -195,6 +403,50 @@ func ResolveImportLocalPath(rootPath string, importPath string) (string, error)
 	return importPath, nil
 }
 
-func ReadFromLocalPath(path string) ([]map[string]interface{}, error) {
-	return parser.Parse([]string{path}, false)
+// ResolveImportPathOrURL is like ResolveImportLocalPath, except that imports in
+// a module fetched over http(s) are resolved relative to the module's URL.
+func ResolveImportPathOrURL(rootPath string, importPath string) (string, error) {
+	if parser.IsURL(importPath) {
+		return importPath, nil
+	}
+
+	if !parser.IsURL(rootPath) {
+		return ResolveImportLocalPath(rootPath, importPath)
+	}
+
+	base, err := url.Parse(rootPath)
+	if err != nil {
+		return "", serrors.InvalidValueContextErrorf(err, rootPath, "parsing module url")
+	}
+
+	ref, err := url.Parse(filepath.ToSlash(importPath))
+	if err != nil {
+		return "", serrors.InvalidValueContextErrorf(err, importPath, "parsing import path")
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
+
+func ReadFromLocalPath(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
+	return readFromStream(parser.OpenFile, path)
+}
+
+// ReadFromPathOrURL reads a module from a local file or an http(s) URL.
+func ReadFromPathOrURL(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
+	return readFromStream(parser.OpenStream, path)
+}
+
+func readFromStream(open parser.StreamOpener, path string) ([]map[string]interface{}, parser.SourceMaps, error) {
+	stream, err := open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stream.Close()
+
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, nil, serrors.ContextualizeErrorf(err, "reading %s", path)
+	}
+
+	return parser.ParseSource(path, data)
 }
@@ -2,10 +2,15 @@ package imports
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/kr/pretty"
 
+	"github.com/koki/short/parser"
 	"github.com/koki/short/yaml"
 )
 
@@ -58,6 +63,43 @@ params:
 - param0: a param
 export0: ${param0}
 export1: asdf
+`,
+	"diamond": `
+imports:
+- left: module2
+- right: module2
+value: thing
+`,
+	"self": `
+imports:
+- me: self
+value: thing
+`,
+	"cycleA": `
+imports:
+- b: cycleB
+value: thing
+`,
+	"cycleB": `
+imports:
+- a: cycleA
+value: thing
+`,
+	"cycleRoot": `
+imports:
+- next: cycleX
+value: thing
+`,
+	"cycleX": `
+imports:
+- next: cycleY
+value: thing
+`,
+	"cycleY": `
+imports:
+- module: module1
+- next: cycleX
+value: thing
 `,
 }
 
@@ -66,16 +108,16 @@ func getEvalContext(t *testing.T) *EvalContext {
 		ResolveImportPath: func(rootPath string, importPath string) (string, error) {
 			return importPath, nil
 		},
-		ReadFromPath: func(path string) ([]map[string]interface{}, error) {
+		ReadFromPath: func(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
 			if contents, ok := modules[path]; ok {
 				obj := map[string]interface{}{}
 				err := yaml.Unmarshal([]byte(contents), &obj)
 				if err != nil {
 					t.Error(err)
 				}
-				return []map[string]interface{}{obj}, nil
+				return []map[string]interface{}{obj}, nil, nil
 			}
-			return nil, fmt.Errorf("no module (%s)", path)
+			return nil, nil, fmt.Errorf("no module (%s)", path)
 		},
 	}
 }
@@ -90,6 +132,31 @@ func TestImports(t *testing.T) {
 	doTestImport("module6", t, true)
 	doTestImport("module7", t, false)
 	doTestImport("module8", t, true)
+	doTestImport("diamond", t, false)
+}
+
+func TestImportCycles(t *testing.T) {
+	expectedCycles := map[string]string{
+		"self":      "import cycle: self#0 -> self#0 (import names: me)",
+		"cycleA":    "import cycle: cycleA -> cycleB -> cycleA (import names: b, a)",
+		"cycleB":    "import cycle: cycleB -> cycleA -> cycleB (import names: a, b)",
+		"cycleRoot": "import cycle: cycleX -> cycleY -> cycleX (import names: next, next)",
+	}
+
+	for modulePath, expected := range expectedCycles {
+		evalContext := getEvalContext(t)
+		_, err := evalContext.Parse(modulePath)
+		if err == nil {
+			t.Errorf("%s: expected an import cycle error", modulePath)
+			continue
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error to contain\n%s\ngot\n%s", modulePath, expected, err.Error())
+		}
+		if len(evalContext.parsing) != 0 {
+			t.Errorf("%s: expected parsing chain to be unwound, got %v", modulePath, evalContext.parsing)
+		}
+	}
 }
 
 func doTestImport(modulePath string, t *testing.T, expectParseError bool) {
@@ -109,3 +176,106 @@ func doTestImport(modulePath string, t *testing.T, expectParseError bool) {
 		t.Error(pretty.Sprintf("expected only one module\n%# v", modules))
 	}
 }
+
+var urlModules = map[string]string{
+	"/modules/app.yaml": `
+imports:
+- sidecar: ./sidecar.yaml
+- labels: ../common/labels.yaml
+value:
+- ${sidecar}
+- ${labels}
+`,
+	"/modules/sidecar.yaml": `
+value: sidecar
+`,
+	"/common/labels.yaml": `
+value: labels
+`,
+}
+
+func TestImportsFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contents, ok := urlModules[r.URL.Path]; ok {
+			fmt.Fprint(w, contents)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	evalContext := &EvalContext{
+		RawToTyped: func(raw interface{}) (interface{}, error) {
+			return raw, nil
+		},
+		ResolveImportPath: ResolveImportPathOrURL,
+		ReadFromPath:      ReadFromPathOrURL,
+	}
+
+	modules, err := evalContext.Parse(server.URL + "/modules/app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	module := &modules[0]
+	err = evalContext.EvaluateModule(module, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"value": []interface{}{"sidecar", "labels"},
+	}
+	if !reflect.DeepEqual(module.Export.Raw, expected) {
+		t.Fatal(pretty.Sprintf("evaluated module doesn't match expected\n(%# v)\n(%# v)", module.Export.Raw, expected))
+	}
+
+	_, err = evalContext.Parse(server.URL + "/modules/missing.yaml")
+	if err == nil {
+		t.Fatal("expected an error for a missing module")
+	}
+}
+
+func TestResolveImportPathOrURL(t *testing.T) {
+	for _, test := range []struct {
+		rootPath, importPath, expected string
+	}{
+		{"http://host/a/b.yaml", "./c.yaml", "http://host/a/c.yaml"},
+		{"http://host/a/b.yaml", "../c.yaml", "http://host/c.yaml"},
+		{"http://host/a/b.yaml", "https://other/c.yaml", "https://other/c.yaml"},
+		{"dir/b.yaml", "./c.yaml", "dir/c.yaml"},
+		{"dir/b.yaml", "https://other/c.yaml", "https://other/c.yaml"},
+	} {
+		actual, err := ResolveImportPathOrURL(test.rootPath, test.importPath)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("resolving (%s) from (%s): expected (%s), got (%s)", test.importPath, test.rootPath, test.expected, actual)
+		}
+	}
+}
+
+func TestParseObjects(t *testing.T) {
+	evalContext := getEvalContext(t)
+	evalContext.ResolveImportPath = ResolveImportLocalPath
+
+	obj := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(modules["module2"]), &obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Imports are resolved relative to the directory of the given path.
+	modules, err := evalContext.ParseObjects("stdin", []map[string]interface{}{obj}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 || len(modules[0].Imports) != 1 || modules[0].Imports[0].Path != "module1" {
+		t.Fatal(pretty.Sprintf("expected one module importing module1\n(%# v)", modules))
+	}
+	if modules[0].Path != "stdin" {
+		t.Errorf("expected module path (stdin), got (%s)", modules[0].Path)
+	}
+}
@@ -0,0 +1,82 @@
+package imports
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+// DefaultLockFile is the name of the lock file for remote modules.
+const DefaultLockFile = "short.lock"
+
+const lockFileHeader = "# Generated by short. Pins the remote modules imported by koki modules.\n"
+
+// LockFile records the version (e.g. a git commit) and sha256 digest of each remote module.
+type LockFile struct {
+	Path    string                  `json:"-"`
+	Modules map[string]LockedModule `json:"modules"`
+
+	changed bool
+}
+
+type LockedModule struct {
+	// Version is empty if the module's source doesn't have versions (e.g. https).
+	Version string `json:"version,omitempty"`
+	SHA256  string `json:"sha256"`
+}
+
+// ReadLockFile reads the lock file at path. A missing lock file is empty.
+func ReadLockFile(path string) (*LockFile, error) {
+	lock := &LockFile{Path: path, Modules: map[string]LockedModule{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "reading lock file %s", path)
+	}
+
+	err = yaml.Unmarshal(b, lock)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, string(b), "parsing lock file %s", path)
+	}
+	if lock.Modules == nil {
+		lock.Modules = map[string]LockedModule{}
+	}
+
+	return lock, nil
+}
+
+func (l *LockFile) set(path string, module LockedModule) {
+	if l.Modules[path] != module {
+		l.Modules[path] = module
+		l.changed = true
+	}
+}
+
+// Changed is true if modules were added or updated since the lock file was read.
+func (l *LockFile) Changed() bool {
+	return l.changed
+}
+
+// Write saves the lock file if it changed.
+func (l *LockFile) Write() error {
+	if !l.changed {
+		return nil
+	}
+
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return serrors.InvalidInstanceContextErrorf(err, l, "marshalling lock file")
+	}
+
+	err = ioutil.WriteFile(l.Path, append([]byte(lockFileHeader), b...), 0644)
+	if err != nil {
+		return serrors.ContextualizeErrorf(err, "writing lock file %s", l.Path)
+	}
+
+	l.changed = false
+	return nil
+}
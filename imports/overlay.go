@@ -0,0 +1,299 @@
+package imports
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/koki/json/jsonutil"
+	"github.com/koki/short/parser"
+	"github.com/koki/short/template"
+	serrors "github.com/koki/structurederrors"
+)
+
+/*
+
+Overlays change an imported resource at the import site, without the module exposing a param for it.
+
+  imports:
+  - web: ./web.short.yaml
+    overrides:          # deep-merged into the resource
+      labels:
+        team: infra
+      containers:
+      - name: web       # list items with names are merged by name
+        image: nginx:1.13
+    patch:              # then, JSON-patch-style operations
+    - op: remove
+      path: /containers/web/cpu
+
+*/
+
+// applyOverlays fills the template holes in an import's overrides and patch (like its params),
+// then applies them to the evaluated resource, so ResolverForModule resolves the import to the result.
+func (c *EvalContext) applyOverlays(inModule *Module, inModuleParams map[string]interface{}, imprt *Import) error {
+	if imprt.Overrides == nil && len(imprt.Patch) == 0 {
+		return nil
+	}
+
+	resolver := c.ResolverForModule(inModule, inModuleParams)
+	overrides, err := template.ReplaceAny(imprt.Overrides, resolver)
+	if err != nil {
+		return err
+	}
+	patch, err := template.ReplaceSlice(imprt.Patch, resolver)
+	if err != nil {
+		return err
+	}
+
+	export := &imprt.Module.Export
+	kind, val, err := jsonutil.GetOnlyMapEntry(export.Raw)
+	if err != nil {
+		return serrors.InvalidValueContextErrorf(err, export.Raw, "module should export exactly one top-level key")
+	}
+
+	if overrides != nil {
+		val = StrategicMerge(val, overrides)
+	}
+	val, err = ApplyPatch(val, patch)
+	if err != nil {
+		return err
+	}
+
+	export.Raw = map[string]interface{}{kind: val}
+	export.TypedResult, err = c.RawToTyped(export.Raw)
+	if err != nil {
+		export.TypedResult = parser.LocateError(err, imprt.Module.SourceMap)
+	}
+
+	return nil
+}
+
+// StrategicMerge deep-merges overrides into a copy of base.
+//
+// Maps are merged key by key, and a null value removes the key.
+// Lists are merged item by item if every item has a name (see listItemName):
+// items with the same name are merged, and new items are appended.
+// Other lists, and any other values, are replaced.
+func StrategicMerge(base, overrides interface{}) interface{} {
+	switch overrides := overrides.(type) {
+	case map[string]interface{}:
+		baseMap, ok := base.(map[string]interface{})
+		if !ok {
+			return copyParamValue(removeNulls(overrides))
+		}
+
+		merged := MergeParams(baseMap, nil)
+		for key, val := range overrides {
+			if val == nil {
+				delete(merged, key)
+				continue
+			}
+			merged[key] = StrategicMerge(merged[key], val)
+		}
+		return merged
+	case []interface{}:
+		baseList, ok := base.([]interface{})
+		if !ok || !allNamed(baseList) || !allNamed(overrides) {
+			return copyParamValue(overrides)
+		}
+
+		merged := copyParamValue(baseList).([]interface{})
+		for _, item := range overrides {
+			name, _ := listItemName(item)
+			i := indexOfName(merged, name)
+			if i < 0 {
+				merged = append(merged, copyParamValue(item))
+			} else {
+				merged[i] = StrategicMerge(merged[i], item)
+			}
+		}
+		return merged
+	default:
+		return overrides
+	}
+}
+
+func removeNulls(m map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, val := range m {
+		if val != nil {
+			result[key] = val
+		}
+	}
+
+	return result
+}
+
+// listItemName identifies an item in a list of named items, e.g.
+//
+//	containers: {name: web, ...}   -> web
+//	env:        FOO=bar            -> FOO
+//	            {key: FOO, from: ...} -> FOO
+//	expose:     {http: 8080}       -> http
+func listItemName(item interface{}) (string, bool) {
+	switch item := item.(type) {
+	case string:
+		i := strings.Index(item, "=")
+		if i > 0 {
+			return item[:i], true
+		}
+	case map[string]interface{}:
+		if name, ok := item["name"].(string); ok {
+			return name, true
+		}
+		if len(item) == 1 {
+			for key := range item {
+				return key, true
+			}
+		}
+		if key, ok := item["key"].(string); ok {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+func allNamed(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := listItemName(item); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func indexOfName(list []interface{}, name string) int {
+	for i, item := range list {
+		if itemName, ok := listItemName(item); ok && itemName == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ApplyPatch applies JSON-patch-style operations (add, replace, or remove) to a copy of value.
+//
+// Paths are JSON pointers, e.g. /containers/0/image. In a list, a path segment
+// can also be the name of an item (see listItemName), and "-" adds to the end.
+func ApplyPatch(value interface{}, ops []interface{}) (interface{}, error) {
+	result := copyParamValue(value)
+	for i, op := range ops {
+		var err error
+		result, err = applyPatchOp(result, op)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "patch (%d)", i)
+		}
+	}
+
+	return result, nil
+}
+
+func applyPatchOp(value interface{}, rawOp interface{}) (interface{}, error) {
+	op, ok := rawOp.(map[string]interface{})
+	if !ok {
+		return nil, serrors.InvalidValueErrorf(rawOp, "expected a map with op, path and value")
+	}
+	opName, _ := op["op"].(string)
+	path, ok := op["path"].(string)
+	if !ok || !strings.HasPrefix(path, "/") {
+		return nil, serrors.InvalidValueErrorf(op["path"], "expected a path starting with /")
+	}
+	newVal, hasValue := op["value"]
+
+	switch opName {
+	case "add", "replace":
+		if !hasValue {
+			return nil, serrors.InvalidValueErrorf(op, "expected a value for (%s)", opName)
+		}
+	case "remove":
+	default:
+		return nil, serrors.InvalidValueErrorf(opName, "expected op to be add, replace, or remove")
+	}
+
+	segments := strings.Split(path[1:], "/")
+	for i, segment := range segments {
+		// JSON pointer escapes
+		segments[i] = strings.Replace(strings.Replace(segment, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	result, err := patchAt(value, segments, opName, newVal)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "%s %s", opName, path)
+	}
+
+	return result, nil
+}
+
+func patchAt(value interface{}, segments []string, op string, newVal interface{}) (interface{}, error) {
+	segment := segments[0]
+	last := len(segments) == 1
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		child, exists := value[segment]
+		if last {
+			switch {
+			case op == "add":
+				value[segment] = newVal
+			case !exists:
+				return nil, serrors.InvalidValueErrorf(segment, "key not found")
+			case op == "replace":
+				value[segment] = newVal
+			default:
+				delete(value, segment)
+			}
+			return value, nil
+		}
+
+		if !exists {
+			return nil, serrors.InvalidValueErrorf(segment, "key not found")
+		}
+		child, err := patchAt(child, segments[1:], op, newVal)
+		if err != nil {
+			return nil, err
+		}
+		value[segment] = child
+		return value, nil
+	case []interface{}:
+		if segment == "-" {
+			if !last || op != "add" {
+				return nil, serrors.InvalidValueErrorf(segment, "can only add to the end of a list")
+			}
+			return append(value, newVal), nil
+		}
+
+		i, err := strconv.Atoi(segment)
+		if err != nil {
+			i = indexOfName(value, segment)
+		}
+		if i < 0 || i > len(value) || (i == len(value) && !(last && op == "add")) {
+			return nil, serrors.InvalidValueErrorf(segment, "list item not found")
+		}
+
+		if last {
+			switch op {
+			case "add":
+				value = append(value, nil)
+				copy(value[i+1:], value[i:])
+				value[i] = newVal
+			case "replace":
+				value[i] = newVal
+			default:
+				value = append(value[:i], value[i+1:]...)
+			}
+			return value, nil
+		}
+
+		child, err := patchAt(value[i], segments[1:], op, newVal)
+		if err != nil {
+			return nil, err
+		}
+		value[i] = child
+		return value, nil
+	default:
+		return nil, serrors.InvalidValueErrorf(value, "can only patch inside a list or map")
+	}
+}
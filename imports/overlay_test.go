@@ -0,0 +1,260 @@
+package imports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+
+	"github.com/koki/short/yaml"
+)
+
+func parseYAMLValue(t *testing.T, str string) interface{} {
+	var val interface{}
+	err := yaml.Unmarshal([]byte(str), &val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return val
+}
+
+func TestStrategicMerge(t *testing.T) {
+	testCases := []struct {
+		base, overrides, expected string
+	}{
+		{
+			base:      `{a: 1, b: {c: 2, d: 3}}`,
+			overrides: `{b: {c: 4, e: 5}, f: 6}`,
+			expected:  `{a: 1, b: {c: 4, d: 3, e: 5}, f: 6}`,
+		},
+		{
+			base:      `{a: 1, b: 2}`,
+			overrides: `{b: null}`,
+			expected:  `{a: 1}`,
+		},
+		{
+			// Lists without names are replaced.
+			base:      `{args: [a, b]}`,
+			overrides: `{args: [c]}`,
+			expected:  `{args: [c]}`,
+		},
+		{
+			base: `
+containers:
+- name: web
+  image: nginx
+  env: [A=1, B=2]
+  expose: [{http: 80}]
+- name: sidecar
+  image: envoy
+`,
+			overrides: `
+containers:
+- name: web
+  image: nginx:1.13
+  env: [B=3, C=4]
+  expose: [{http: 8080}, {admin: 9090}]
+- name: logger
+  image: fluentd
+`,
+			expected: `
+containers:
+- name: web
+  image: nginx:1.13
+  env: [A=1, B=3, C=4]
+  expose: [{http: 8080}, {admin: 9090}]
+- name: sidecar
+  image: envoy
+- name: logger
+  image: fluentd
+`,
+		},
+		{
+			base:      `{env: [{key: A, from: config:a}, B=2]}`,
+			overrides: `{env: [{key: A, required: true}]}`,
+			expected:  `{env: [{key: A, from: config:a, required: true}, B=2]}`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		base := parseYAMLValue(t, testCase.base)
+		baseCopy := copyParamValue(base)
+		merged := StrategicMerge(base, parseYAMLValue(t, testCase.overrides))
+		expected := parseYAMLValue(t, testCase.expected)
+		if !reflect.DeepEqual(merged, expected) {
+			t.Error(pretty.Sprintf("case %d: merged value doesn't match expected\n(%# v)\n(%# v)", i, merged, expected))
+		}
+		if !reflect.DeepEqual(base, baseCopy) {
+			t.Errorf("case %d: base was modified", i)
+		}
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	base := `
+containers:
+- name: web
+  image: nginx
+  cpu: {min: 100m}
+- name: sidecar
+  image: envoy
+`
+	testCases := []struct {
+		patch, expected string
+
+		// expectedErr is empty if the patch should succeed.
+		expectedErr string
+	}{
+		{
+			patch: `[{op: replace, path: /containers/0/image, value: "nginx:1.13"}]`,
+			expected: `
+containers:
+- {name: web, image: "nginx:1.13", cpu: {min: 100m}}
+- {name: sidecar, image: envoy}
+`,
+		},
+		{
+			patch: `[{op: remove, path: /containers/web/cpu}, {op: remove, path: /containers/sidecar}]`,
+			expected: `
+containers:
+- {name: web, image: nginx}
+`,
+		},
+		{
+			patch: `[{op: add, path: /containers/-, value: {name: logger}}, {op: add, path: /containers/0, value: {name: init}}]`,
+			expected: `
+containers:
+- {name: init}
+- {name: web, image: nginx, cpu: {min: 100m}}
+- {name: sidecar, image: envoy}
+- {name: logger}
+`,
+		},
+		{
+			patch:    `[{op: add, path: /labels, value: {a/b: c}}, {op: replace, path: /labels/a~1b, value: d}]`,
+			expected: base + "labels: {a/b: d}\n",
+		},
+		{
+			patch:       `[{op: remove, path: /containers/logger}]`,
+			expectedErr: "list item not found",
+		},
+		{
+			patch:       `[{op: replace, path: /labels, value: {}}]`,
+			expectedErr: "key not found",
+		},
+		{
+			patch:       `[{op: move, path: /containers}]`,
+			expectedErr: "expected op to be add, replace, or remove",
+		},
+		{
+			patch:       `[{op: add, path: containers}]`,
+			expectedErr: "expected a path starting with /",
+		},
+	}
+
+	for i, testCase := range testCases {
+		baseVal := parseYAMLValue(t, base)
+		patched, err := ApplyPatch(baseVal, parseYAMLValue(t, testCase.patch).([]interface{}))
+		if len(testCase.expectedErr) > 0 {
+			if err == nil {
+				t.Errorf("case %d: expected an error", i)
+			} else if !strings.Contains(err.Error(), testCase.expectedErr) {
+				t.Errorf("case %d: expected error to contain\n%s\ngot\n%s", i, testCase.expectedErr, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err.Error())
+			continue
+		}
+
+		expected := parseYAMLValue(t, testCase.expected)
+		if !reflect.DeepEqual(patched, expected) {
+			t.Error(pretty.Sprintf("case %d: patched value doesn't match expected\n(%# v)\n(%# v)", i, patched, expected))
+		}
+		if !reflect.DeepEqual(baseVal, parseYAMLValue(t, base)) {
+			t.Errorf("case %d: base was modified", i)
+		}
+	}
+}
+
+func TestImportOverrides(t *testing.T) {
+	sources := map[string]string{
+		"web": `
+params:
+- image: the web image
+  default: nginx
+pod:
+  labels:
+    app: web
+  containers:
+  - name: web
+    image: ${image}
+    env: [MODE=prod]
+  - name: sidecar
+    image: envoy
+`,
+		"app": `
+imports:
+- web: web
+  params:
+    image: "nginx:1.13"
+  overrides:
+    labels:
+      team: ${team}
+    containers:
+    - name: web
+      env: [DEBUG=true]
+  patch:
+  - op: remove
+    path: /containers/sidecar
+- plain: web
+params:
+- team: the owning team
+pod:
+  labels: ${web.labels}
+  containers:
+  - ${web.containers.0}
+  - ${plain.containers.1}
+`,
+	}
+
+	evalContext := countingEvalContext(sources, map[string]int{})
+	modules, err := evalContext.Parse("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := &modules[0]
+	err = evalContext.EvaluateModule(module, map[string]interface{}{"team": "infra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := parseYAMLValue(t, `
+pod:
+  labels: {app: web, team: infra}
+  containers:
+  - {name: web, image: "nginx:1.13", env: [MODE=prod, DEBUG=true]}
+  - {name: sidecar, image: envoy}
+`)
+	if !reflect.DeepEqual(module.Export.Raw, expected) {
+		t.Fatal(pretty.Sprintf("evaluated module doesn't match expected\n(%# v)\n(%# v)", module.Export.Raw, expected))
+	}
+
+	expectedErr := "overriding import (web)"
+	sources["app"] = strings.Replace(sources["app"], "/containers/sidecar", "/containers/logger", 1)
+	evalContext = countingEvalContext(sources, map[string]int{})
+	modules, err = evalContext.Parse("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = evalContext.EvaluateModule(&modules[0], map[string]interface{}{"team": "infra"})
+	if err == nil {
+		t.Fatal("expected an error for a bad patch")
+	}
+	if !strings.Contains(err.Error(), expectedErr) {
+		t.Fatalf("expected error to contain\n%s\ngot\n%s", expectedErr, err.Error())
+	}
+}
@@ -0,0 +1,134 @@
+package imports
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+
+	serrors "github.com/koki/structurederrors"
+)
+
+// ParamTypes are the builtin types a param can declare.
+// Any other type is interpreted as a koki resource kind (see EvalContext.ParseParamKind).
+var ParamTypes = map[string]func(val interface{}) bool{
+	"string": func(val interface{}) bool {
+		_, ok := val.(string)
+		return ok
+	},
+	"number": func(val interface{}) bool {
+		switch val.(type) {
+		case float64, float32, int, int32, int64:
+			return true
+		default:
+			return false
+		}
+	},
+	"bool": func(val interface{}) bool {
+		_, ok := val.(bool)
+		return ok
+	},
+	"list": func(val interface{}) bool {
+		_, ok := val.([]interface{})
+		return ok
+	},
+	"map": func(val interface{}) bool {
+		_, ok := val.(map[string]interface{})
+		return ok
+	},
+}
+
+// MissingParams lists the required params of a module that don't have a value in params or a default.
+func (m *Module) MissingParams(params map[string]interface{}) []string {
+	missing := []string{}
+	for name, def := range m.Params {
+		if _, ok := params[name]; !ok && def.Required && def.Default == nil {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// validateParams checks the values given for a module's params (including defaults) against their definitions.
+func (c *EvalContext) validateParams(module *Module, params map[string]interface{}) error {
+	names := []string{}
+	for name := range module.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def := module.Params[name]
+		val, ok := params[name]
+		if !ok {
+			if def.Required {
+				return serrors.InvalidValueErrorf(name, "missing required param (%s) for module (%s)", name, module.Path)
+			}
+			continue
+		}
+
+		err := c.validateParam(def, val)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "param (%s) for module (%s)", name, module.Path)
+		}
+	}
+
+	return nil
+}
+
+func (c *EvalContext) validateParam(def ParamDef, val interface{}) error {
+	if len(def.Type) > 0 {
+		if isType, ok := ParamTypes[def.Type]; ok {
+			if !isType(val) {
+				return serrors.InvalidValueErrorf(val, "expected a %s", def.Type)
+			}
+		} else if c.ParseParamKind == nil {
+			return serrors.InvalidValueErrorf(def.Type, "unsupported param type")
+		} else {
+			_, err := c.ParseParamKind(def.Type, val)
+			if err != nil {
+				return serrors.ContextualizeErrorf(err, "expected a %s", def.Type)
+			}
+		}
+	}
+
+	if len(def.Enum) > 0 {
+		found := false
+		for _, option := range def.Enum {
+			if reflect.DeepEqual(val, option) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return serrors.InvalidValueErrorf(val, "expected one of %s", formatEnum(def.Enum))
+		}
+	}
+
+	if len(def.Pattern) > 0 {
+		str, ok := val.(string)
+		if !ok {
+			return serrors.InvalidValueErrorf(val, "expected a string matching (%s)", def.Pattern)
+		}
+		pattern, err := regexp.Compile("^(?:" + def.Pattern + ")$")
+		if err != nil {
+			return serrors.InvalidValueContextErrorf(err, def.Pattern, "invalid pattern")
+		}
+		if !pattern.MatchString(str) {
+			return serrors.InvalidValueErrorf(val, "expected a string matching (%s)", def.Pattern)
+		}
+	}
+
+	return nil
+}
+
+func formatEnum(enum []interface{}) string {
+	options := make([]string, len(enum))
+	for i, option := range enum {
+		options[i] = fmt.Sprintf("%v", option)
+	}
+
+	return fmt.Sprintf("%q", options)
+}
@@ -0,0 +1,154 @@
+package imports
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koki/short/parser"
+)
+
+var paramModules = map[string]string{
+	"sidecar": `
+params:
+- name: the container name
+  type: string
+  required: true
+  pattern: "[a-z][a-z0-9-]*"
+- port: the container port
+  type: number
+  default: 8080
+- pull: the image pull policy
+  enum: [always, never]
+  default: always
+- resources: extra settings for the container
+  type: container
+  default: {}
+container:
+  name: ${name}
+  image: nginx
+  pull: ${pull}
+  expose:
+  - ${port}
+`,
+}
+
+func TestParamValidation(t *testing.T) {
+	testCases := []struct {
+		imprt string
+
+		// expectedErr is empty if the import should succeed.
+		expectedErr string
+	}{
+		{imprt: `{name: web}`},
+		{imprt: `{name: web, port: 80, pull: never, resources: {cpu: {min: 100m}}}`},
+		{imprt: `{}`, expectedErr: "missing required param (name) for module (sidecar)"},
+		{imprt: `{name: Web}`, expectedErr: "expected a string matching ([a-z][a-z0-9-]*)"},
+		{imprt: `{name: 42}`, expectedErr: "expected a string"},
+		{imprt: `{name: web, port: eighty}`, expectedErr: "expected a number"},
+		{imprt: `{name: web, pull: sometimes}`, expectedErr: `expected one of ["always" "never"]`},
+		{imprt: `{name: web, resources: {cpus: 1}}`, expectedErr: "expected a container"},
+	}
+
+	for i, testCase := range testCases {
+		sources := map[string]string{
+			"sidecar": paramModules["sidecar"],
+			"pod": fmt.Sprintf(`
+imports:
+- web_sidecar: sidecar
+  params: %s
+pod:
+  containers:
+  - ${web_sidecar}
+`, testCase.imprt),
+		}
+		evalContext := countingEvalContext(sources, map[string]int{})
+		evalContext.ParseParamKind = parser.ParseKokiNativeValue
+
+		modules, err := evalContext.Parse("pod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = evalContext.EvaluateModule(&modules[0], nil)
+		if len(testCase.expectedErr) == 0 {
+			if err != nil {
+				t.Errorf("case %d: unexpected error: %s", i, err.Error())
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("case %d: expected error containing (%s)", i, testCase.expectedErr)
+			continue
+		}
+		for _, expected := range []string{testCase.expectedErr, "import (web_sidecar) in module (pod)", "for module (sidecar)"} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("case %d: expected error containing (%s), got\n%s", i, expected, err.Error())
+			}
+		}
+	}
+}
+
+func TestMissingParams(t *testing.T) {
+	evalContext := countingEvalContext(map[string]string{"sidecar": paramModules["sidecar"]}, map[string]int{})
+	modules, err := evalContext.Parse("sidecar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := modules[0].MissingParams(map[string]interface{}{"port": 80})
+	if !reflect.DeepEqual(missing, []string{"name"}) {
+		t.Errorf("expected the required name param to be missing, got %v", missing)
+	}
+	if missing := modules[0].MissingParams(map[string]interface{}{"name": "web"}); len(missing) > 0 {
+		t.Errorf("expected no missing params, got %v", missing)
+	}
+}
+
+func TestParseParamDef(t *testing.T) {
+	for _, def := range []interface{}{
+		map[string]interface{}{"type": "string", "required": true},
+		map[string]interface{}{"name": "a param", "required": "yes"},
+		map[string]interface{}{"name": "a param", "pattern": "("},
+		map[string]interface{}{"param": "type", "desc": "a typo"},
+	} {
+		_, _, err := parseParamDef(def)
+		if err == nil {
+			t.Errorf("expected an error for (%v)", def)
+		}
+	}
+
+	testCases := []struct {
+		def          interface{}
+		expectedName string
+		expectedDef  ParamDef
+	}{
+		{
+			// A single key is a name, even if it's also a setting.
+			def:          map[string]interface{}{"type": "the service type"},
+			expectedName: "type",
+			expectedDef:  ParamDef{Description: "the service type"},
+		},
+		{
+			def:          map[string]interface{}{"param": "type", "description": "the service type", "default": "ClusterIP"},
+			expectedName: "type",
+			expectedDef:  ParamDef{Description: "the service type", Default: "ClusterIP"},
+		},
+		{
+			def:          map[string]interface{}{"name": "the container name", "type": "string"},
+			expectedName: "name",
+			expectedDef:  ParamDef{Description: "the container name", Type: "string"},
+		},
+	}
+	for _, testCase := range testCases {
+		name, def, err := parseParamDef(testCase.def)
+		if err != nil {
+			t.Errorf("unexpected error for (%v): %s", testCase.def, err.Error())
+			continue
+		}
+		if name != testCase.expectedName || !reflect.DeepEqual(def, testCase.expectedDef) {
+			t.Errorf("expected (%s) %#v, got (%s) %#v", testCase.expectedName, testCase.expectedDef, name, def)
+		}
+	}
+}
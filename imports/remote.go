@@ -0,0 +1,217 @@
+package imports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// RemoteSource fetches modules from a kind of remote location, e.g. https or git.
+type RemoteSource interface {
+	// Matches is true if path is a module from this source.
+	Matches(path string) bool
+
+	// Resolve pins path to an exact version (e.g. a git commit).
+	// Sources without versions return "".
+	Resolve(path string) (string, error)
+
+	// Fetch reads the contents of path at the given version.
+	Fetch(path, version string) ([]byte, error)
+
+	// ResolveImport resolves importPath relative to the module at rootPath.
+	ResolveImport(rootPath, importPath string) (string, error)
+}
+
+// RemoteModules reads modules from local files and from its Sources.
+// Remote modules are kept in a download cache, and pinned by Lock: a module's
+// version and digest are recorded the first time it's read, and it must match
+// them every time after.
+type RemoteModules struct {
+	Sources []RemoteSource
+
+	// CacheDir stores downloaded modules by digest. Nothing is cached if it's empty.
+	CacheDir string
+
+	Lock *LockFile
+
+	// Update resolves modules again instead of using the versions in Lock, and updates Lock.
+	Update bool
+}
+
+func (r *RemoteModules) source(path string) RemoteSource {
+	for _, source := range r.Sources {
+		if source.Matches(path) {
+			return source
+		}
+	}
+
+	return nil
+}
+
+// ResolveImportPath can be used as EvalContext.ResolveImportPath.
+func (r *RemoteModules) ResolveImportPath(rootPath string, importPath string) (string, error) {
+	if r.source(importPath) != nil {
+		if IsGitPath(importPath) {
+			return resolveGitRepo(rootPath, importPath)
+		}
+		return importPath, nil
+	}
+	if source := r.source(rootPath); source != nil {
+		return source.ResolveImport(rootPath, importPath)
+	}
+
+	return ResolveImportLocalPath(rootPath, importPath)
+}
+
+// ReadFromPath can be used as EvalContext.ReadFromPath.
+func (r *RemoteModules) ReadFromPath(path string) ([]map[string]interface{}, parser.SourceMaps, error) {
+	source := r.source(path)
+	if source == nil {
+		return ReadFromLocalPath(path)
+	}
+
+	b, err := r.read(source, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parser.ParseSource(path, b)
+}
+
+func (r *RemoteModules) read(source RemoteSource, path string) ([]byte, error) {
+	locked, isLocked := r.Lock.Modules[path]
+	if !isLocked || r.Update {
+		return r.readUnlocked(source, path)
+	}
+
+	b, ok := r.readCache(locked.SHA256)
+	if !ok {
+		var err error
+		b, err = source.Fetch(path, locked.Version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	digest := sha256Digest(b)
+	if digest != locked.SHA256 {
+		return nil, serrors.InvalidValueErrorf(path, "module doesn't match %s: expected sha256 (%s), got (%s). rerun with --update-lock if the change is expected", r.Lock.Path, locked.SHA256, digest)
+	}
+
+	r.writeCache(digest, b)
+	return b, nil
+}
+
+func (r *RemoteModules) readUnlocked(source RemoteSource, path string) ([]byte, error) {
+	version, err := source.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := source.Fetch(path, version)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256Digest(b)
+	r.Lock.set(path, LockedModule{Version: version, SHA256: digest})
+	r.writeCache(digest, b)
+
+	return b, nil
+}
+
+func (r *RemoteModules) cachePath(digest string) string {
+	return filepath.Join(r.CacheDir, "sha256", digest)
+}
+
+func (r *RemoteModules) readCache(digest string) ([]byte, bool) {
+	if len(r.CacheDir) == 0 {
+		return nil, false
+	}
+
+	b, err := ioutil.ReadFile(r.cachePath(digest))
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+// writeCache is best-effort. Modules can still be fetched without the cache.
+func (r *RemoteModules) writeCache(digest string, b []byte) {
+	if len(r.CacheDir) == 0 {
+		return
+	}
+
+	path := r.cachePath(digest)
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		glog.V(1).Infof("couldn't create module cache: %s", err.Error())
+		return
+	}
+
+	// Write to a temporary file first, so a partial write is never read from the cache.
+	f, err := ioutil.TempFile(filepath.Dir(path), digest)
+	if err != nil {
+		glog.V(1).Infof("couldn't write to module cache: %s", err.Error())
+		return
+	}
+	_, err = f.Write(b)
+	f.Close()
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		glog.V(1).Infof("couldn't write to module cache: %s", err.Error())
+	}
+}
+
+func sha256Digest(b []byte) string {
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+// HTTPSource fetches http:// and https:// modules. They don't have versions,
+// so they're only pinned by their digests.
+type HTTPSource struct {
+	Opener *parser.URLOpener
+}
+
+func (s *HTTPSource) Matches(path string) bool {
+	return parser.IsURL(path)
+}
+
+func (s *HTTPSource) Resolve(path string) (string, error) {
+	return "", nil
+}
+
+func (s *HTTPSource) Fetch(path, version string) ([]byte, error) {
+	opener := s.Opener
+	if opener == nil {
+		opener = parser.DefaultURLOpener
+	}
+
+	stream, err := opener.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	return ioutil.ReadAll(stream)
+}
+
+func (s *HTTPSource) ResolveImport(rootPath, importPath string) (string, error) {
+	return ResolveImportPathOrURL(rootPath, importPath)
+}
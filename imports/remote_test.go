@@ -0,0 +1,258 @@
+package imports
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+)
+
+func remoteEvalContext(remotes *RemoteModules) *EvalContext {
+	return &EvalContext{
+		RawToTyped: func(raw interface{}) (interface{}, error) {
+			return raw, nil
+		},
+		ResolveImportPath: remotes.ResolveImportPath,
+		ReadFromPath:      remotes.ReadFromPath,
+	}
+}
+
+func evaluateRemote(remotes *RemoteModules, path string) (map[string]interface{}, error) {
+	evalContext := remoteEvalContext(remotes)
+	modules, err := evalContext.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	module := &modules[0]
+	err = evalContext.EvaluateModule(module, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return module.Export.Raw, nil
+}
+
+func TestRemoteModulesOverHTTP(t *testing.T) {
+	sources := map[string]string{
+		"/modules/app.yaml": `
+imports:
+- sidecar: ./sidecar.yaml
+value: ${sidecar}
+`,
+		"/modules/sidecar.yaml": `
+value: sidecar
+`,
+	}
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if contents, ok := sources[r.URL.Path]; ok {
+			fmt.Fprint(w, contents)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "short-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newRemotes := func() *RemoteModules {
+		lock, err := ReadLockFile(filepath.Join(dir, DefaultLockFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &RemoteModules{
+			Sources:  []RemoteSource{&HTTPSource{}},
+			CacheDir: filepath.Join(dir, "cache"),
+			Lock:     lock,
+		}
+	}
+
+	appURL := server.URL + "/modules/app.yaml"
+	sidecarURL := server.URL + "/modules/sidecar.yaml"
+	expected := map[string]interface{}{"value": "sidecar"}
+
+	// The first render records the modules in the lock file.
+	remotes := newRemotes()
+	raw, err := evaluateRemote(remotes, appURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(raw, expected) {
+		t.Fatal(pretty.Sprintf("evaluated module doesn't match expected\n(%# v)\n(%# v)", raw, expected))
+	}
+	locked := remotes.Lock.Modules[sidecarURL]
+	if locked.SHA256 != sha256Digest([]byte(sources["/modules/sidecar.yaml"])) {
+		t.Errorf("expected the sidecar's digest in the lock file, got %v", remotes.Lock.Modules)
+	}
+	err = remotes.Lock.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Later renders read locked modules from the cache.
+	_, err = evaluateRemote(newRemotes(), appURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests["/modules/sidecar.yaml"] != 1 {
+		t.Errorf("expected locked module to be read from the cache, got %d requests", requests["/modules/sidecar.yaml"])
+	}
+
+	// A changed module doesn't match the lock file.
+	sources["/modules/sidecar.yaml"] = "value: changed\n"
+	os.RemoveAll(filepath.Join(dir, "cache"))
+	_, err = evaluateRemote(newRemotes(), appURL)
+	if err == nil || !strings.Contains(err.Error(), "module doesn't match") {
+		t.Fatalf("expected a digest mismatch, got %v", err)
+	}
+
+	// Unless the lock is updated.
+	remotes = newRemotes()
+	remotes.Update = true
+	raw, err = evaluateRemote(remotes, appURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["value"] != "changed" || !remotes.Lock.Changed() {
+		t.Errorf("expected the updated module to be locked, got %v", raw)
+	}
+}
+
+func TestRemoteModulesFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir, err := ioutil.TempDir("", "short-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Build a bare repo with a module at tag v1 and a newer one at HEAD.
+	work := filepath.Join(dir, "work")
+	bare := filepath.Join(dir, "repo.git")
+	runGit := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = work
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	writeModule := func(name, contents string) {
+		path := filepath.Join(work, "modules", name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(contents), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.MkdirAll(work, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGit("init", "--quiet")
+	writeModule("app.yaml", "imports:\n- sidecar: ./sidecar.yaml\nvalue: ${sidecar}\n")
+	writeModule("sidecar.yaml", "value: v1\n")
+	runGit("add", "-A")
+	runGit("commit", "--quiet", "-m", "v1")
+	runGit("tag", "v1")
+	v1 := runGit("rev-parse", "HEAD")
+	writeModule("sidecar.yaml", "value: v2\n")
+	runGit("commit", "--quiet", "-am", "v2")
+	runGit("clone", "--quiet", "--bare", work, bare)
+
+	lock, err := ReadLockFile(filepath.Join(dir, DefaultLockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	remotes := &RemoteModules{
+		Sources:  []RemoteSource{&GitSource{CacheDir: filepath.Join(dir, "cache")}},
+		CacheDir: filepath.Join(dir, "cache"),
+		Lock:     lock,
+	}
+
+	appPath := "git+file://" + filepath.ToSlash(bare) + "//modules/app.yaml?ref=v1"
+	raw, err := evaluateRemote(remotes, appPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["value"] != "v1" {
+		t.Errorf("expected the module at tag v1, got %v", raw)
+	}
+
+	sidecarPath := "git+file://" + filepath.ToSlash(bare) + "//modules/sidecar.yaml?ref=v1"
+	if locked := lock.Modules[sidecarPath]; locked.Version != v1 {
+		t.Errorf("expected (%s) to be locked to commit %s, got %v", sidecarPath, v1, lock.Modules)
+	}
+
+	raw, err = evaluateRemote(remotes, strings.TrimSuffix(appPath, "?ref=v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["value"] != "v2" {
+		t.Errorf("expected the module at HEAD, got %v", raw)
+	}
+}
+
+func TestGitSourceResolveImport(t *testing.T) {
+	source := &GitSource{}
+	for _, test := range []struct {
+		rootPath, importPath, expected, expectedErr string
+	}{
+		{
+			rootPath:   "git+file:///repo//modules/app.yaml?ref=v1.2",
+			importPath: "./sidecar.yaml",
+			expected:   "git+file:///repo//modules/sidecar.yaml?ref=v1.2",
+		},
+		{
+			rootPath:   "git+https://host/repo.git//app.yaml",
+			importPath: "common/labels.yaml",
+			expected:   "git+https://host/repo.git//common/labels.yaml?ref=HEAD",
+		},
+		{
+			rootPath:    "git+file:///repo//app.yaml",
+			importPath:  "../other.yaml",
+			expectedErr: "outside of the git repository",
+		},
+		{
+			rootPath:    "git+file:///repo/app.yaml",
+			importPath:  "./other.yaml",
+			expectedErr: "expected the module's path in the repository after //",
+		},
+	} {
+		actual, err := source.ResolveImport(test.rootPath, test.importPath)
+		if len(test.expectedErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("resolving (%s) from (%s): expected error (%s), got %v", test.importPath, test.rootPath, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("resolving (%s) from (%s): expected (%s), got (%s)", test.importPath, test.rootPath, test.expected, actual)
+		}
+	}
+}
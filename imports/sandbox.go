@@ -0,0 +1,100 @@
+package imports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+// ModuleRootError is returned when a module is outside of EvalContext.ModuleRoot.
+type ModuleRootError struct {
+	Root string
+
+	// Resolved is the module's path after following symlinks.
+	Resolved string
+
+	// Chain is the chain of imports that led to the module, ending with it.
+	// Each link's ImportName is the name it imports the next module by.
+	Chain []importLink
+}
+
+func (e *ModuleRootError) Error() string {
+	paths := make([]string, len(e.Chain))
+	names := []string{}
+	for i, link := range e.Chain {
+		paths[i] = link.Path
+		if i < len(e.Chain)-1 {
+			names = append(names, link.ImportName)
+		}
+	}
+
+	module := e.Chain[len(e.Chain)-1].Path
+	msg := fmt.Sprintf("module (%s) resolves to (%s), outside of the module root (%s)", module, e.Resolved, e.Root)
+	if len(names) == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf("%s: imported by %s (import names: %s)", msg, strings.Join(paths, " -> "), strings.Join(names, ", "))
+}
+
+// checkModuleRoot makes sure the module at path (the last one in c.parsing) is inside c.ModuleRoot.
+// Symlinks are followed, so a link can't point outside of the root either.
+// Remote modules are allowed, except for git repositories on the local filesystem (with or without file://).
+func (c *EvalContext) checkModuleRoot(path string) error {
+	if len(c.ModuleRoot) == 0 || parser.IsURL(path) {
+		return nil
+	}
+
+	localPath := path
+	if IsGitPath(path) {
+		module, err := parseGitPath(path)
+		if err != nil {
+			return err
+		}
+		repo, isLocal := module.localRepo()
+		if !isLocal {
+			return nil
+		}
+		localPath = filepath.FromSlash(repo)
+	}
+
+	root, err := resolveLocalPath(c.ModuleRoot)
+	if err != nil {
+		return serrors.InvalidValueContextErrorf(err, c.ModuleRoot, "resolving module root")
+	}
+	resolved, err := resolveLocalPath(localPath)
+	if err != nil {
+		return serrors.InvalidValueContextErrorf(err, path, "resolving module path")
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &ModuleRootError{
+			Root:     c.ModuleRoot,
+			Resolved: resolved,
+			Chain:    append([]importLink{}, c.parsing...),
+		}
+	}
+
+	return nil
+}
+
+// resolveLocalPath makes path absolute and follows its symlinks.
+// If path doesn't exist, it's only made absolute, and reading it will fail later.
+func resolveLocalPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if os.IsNotExist(err) {
+		return abs, nil
+	}
+
+	return resolved, err
+}
@@ -0,0 +1,124 @@
+package imports
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModuleRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "short-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The temp dir may itself be a symlink.
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(dir, "project")
+	files := map[string]string{
+		"secret.yaml":                  "value: secret\n",
+		"project/app.yaml":             "imports:\n- web: ./modules/web.yaml\nvalue: ${web}\n",
+		"project/modules/web.yaml":     "value: web\n",
+		"project/dotdot.yaml":          "imports:\n- web: ./modules/web.yaml\n- secret: ../secret.yaml\nvalue: ${secret}\n",
+		"project/nested.yaml":          "imports:\n- escape: ./modules/escape.yaml\nvalue: ${escape}\n",
+		"project/modules/escape.yaml":  "imports:\n- secret: ../../secret.yaml\nvalue: ${secret}\n",
+		"project/symlinked.yaml":       "imports:\n- secret: ./modules/link.yaml\nvalue: ${secret}\n",
+		"project/symlinked-inner.yaml": "imports:\n- web: ./modules/inner-link.yaml\nvalue: ${web}\n",
+		"project/git-abs.yaml":         "imports:\n- repo: git+" + filepath.ToSlash(filepath.Join(dir, "repo")) + "//app.yaml\nvalue: ${repo}\n",
+		"project/git-rel.yaml":         "imports:\n- repo: git+../repo//app.yaml\nvalue: ${repo}\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(contents), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.Symlink(filepath.Join(dir, "secret.yaml"), filepath.Join(root, "modules", "link.yaml"))
+	if err == nil {
+		err = os.Symlink("web.yaml", filepath.Join(root, "modules", "inner-link.yaml"))
+	}
+	if err != nil {
+		t.Skipf("can't create symlinks: %s", err.Error())
+	}
+
+	testCases := []struct {
+		module string
+
+		// expectedErr is empty if the module should load.
+		expectedErr string
+	}{
+		{module: "app.yaml"},
+		{module: "symlinked-inner.yaml"},
+		{
+			module:      "dotdot.yaml",
+			expectedErr: "imported by " + filepath.Join(root, "dotdot.yaml") + " -> " + filepath.Join(dir, "secret.yaml") + " (import names: secret)",
+		},
+		{
+			module:      "nested.yaml",
+			expectedErr: "(import names: escape, secret)",
+		},
+		{
+			module:      "symlinked.yaml",
+			expectedErr: "resolves to (" + filepath.Join(dir, "secret.yaml") + "), outside of the module root",
+		},
+		{
+			module:      "git-abs.yaml",
+			expectedErr: "resolves to (" + filepath.Join(dir, "repo") + "), outside of the module root",
+		},
+		{
+			// The repository is relative to the importing module, not the working directory.
+			module:      "git-rel.yaml",
+			expectedErr: "resolves to (" + filepath.Join(dir, "repo") + "), outside of the module root",
+		},
+	}
+
+	for _, testCase := range testCases {
+		// Git modules aren't cloned, since they're outside of the root.
+		remotes := &RemoteModules{
+			Sources: []RemoteSource{&GitSource{CacheDir: filepath.Join(dir, "cache")}},
+			Lock:    &LockFile{Modules: map[string]LockedModule{}},
+		}
+		evalContext := &EvalContext{
+			RawToTyped: func(raw interface{}) (interface{}, error) {
+				return raw, nil
+			},
+			ResolveImportPath: remotes.ResolveImportPath,
+			ReadFromPath:      remotes.ReadFromPath,
+			ModuleRoot:        root,
+		}
+
+		_, err := evalContext.Parse(filepath.Join(root, testCase.module))
+		if len(testCase.expectedErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", testCase.module, err.Error())
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: expected a module root error", testCase.module)
+		} else if !strings.Contains(err.Error(), testCase.expectedErr) {
+			t.Errorf("%s: expected error to contain\n%s\ngot\n%s", testCase.module, testCase.expectedErr, err.Error())
+		}
+	}
+
+	// Without a module root, modules can be anywhere.
+	evalContext := &EvalContext{
+		ResolveImportPath: ResolveImportLocalPath,
+		ReadFromPath:      ReadFromLocalPath,
+	}
+	_, err = evalContext.Parse(filepath.Join(root, "dotdot.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,246 @@
+package imports
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/koki/json/jsonutil"
+	"github.com/koki/short/parser"
+	serrors "github.com/koki/structurederrors"
+)
+
+/*
+
+An import can select a section of a multi-document module after a '#' in its path:
+
+  imports:
+  - app: ./app.yaml               # the first section
+  - worker: ./app.yaml#1          # the section at index 1
+  - svc: ./app.yaml#service       # the only service
+  - web: ./app.yaml#service/web   # the service named web
+  - all: ./app.yaml#*             # every section, as a list
+
+*/
+
+const allSections = "*"
+
+// SplitImportPath splits an import path into the module path and its section selector (if any).
+func SplitImportPath(importPath string) (string, string) {
+	i := strings.LastIndex(importPath, "#")
+	if i < 0 {
+		return importPath, ""
+	}
+
+	return importPath[:i], importPath[i+1:]
+}
+
+// selectSections picks the imported sections of a module file, or all of them if the import is for all sections.
+func selectSections(path, section string, modules []Module) ([]*Module, error) {
+	if len(modules) == 0 {
+		return nil, serrors.InvalidValueErrorf(path, "module has no sections to import")
+	}
+
+	if section == allSections {
+		selected := make([]*Module, len(modules))
+		for i := range modules {
+			selected[i] = &modules[i]
+		}
+		return selected, nil
+	}
+
+	if len(section) == 0 {
+		return []*Module{&modules[0]}, nil
+	}
+
+	if i, err := strconv.Atoi(section); err == nil {
+		if i < 0 || i >= len(modules) {
+			return nil, serrors.InvalidValueErrorf(section, "module (%s) has %d sections", path, len(modules))
+		}
+		return []*Module{&modules[i]}, nil
+	}
+
+	kind, name := section, ""
+	if i := strings.Index(section, "/"); i >= 0 {
+		kind, name = section[:i], section[i+1:]
+	}
+
+	selected := []*Module{}
+	for i := range modules {
+		if sectionMatches(&modules[i], kind, name) {
+			selected = append(selected, &modules[i])
+		}
+	}
+
+	switch len(selected) {
+	case 0:
+		return nil, serrors.InvalidValueErrorf(section, "no section of module (%s) matches", path)
+	case 1:
+		return selected, nil
+	default:
+		return nil, serrors.InvalidValueErrorf(section, "%d sections of module (%s) match. select one by index or kind/name", len(selected), path)
+	}
+}
+
+// isLocalImport checks whether an import in the module file at path is for another of its sections.
+func isLocalImport(path string, imprt *Import) bool {
+	return moduleKey(imprt.Path) == moduleKey(path)
+}
+
+// resolveLocalImports resolves the imports of other sections of the same module file, once all of its sections are parsed.
+// They're resolved to copies of the sections, so evaluating a section doesn't change the ones that import it.
+func resolveLocalImports(path string, modules []Module) error {
+	sections := make([]Module, len(modules))
+	for i := range modules {
+		sections[i] = *modules[i].clone()
+	}
+
+	_, err := resolveSectionImports(path, modules, sections)
+	if err != nil {
+		return err
+	}
+
+	// The copies import each other too, so check them for cycles.
+	imported, err := resolveSectionImports(path, sections, sections)
+	if err != nil {
+		return err
+	}
+
+	return checkSectionCycles(path, modules, imported)
+}
+
+// resolveSectionImports resolves the local imports of modules to sections, and lists the sections that each module imports.
+func resolveSectionImports(path string, modules, sections []Module) ([][]sectionImport, error) {
+	imported := make([][]sectionImport, len(modules))
+	for i := range modules {
+		for _, imprt := range modules[i].Imports {
+			if !isLocalImport(path, imprt) {
+				continue
+			}
+
+			selected, err := selectSections(path, imprt.Section, sections)
+			if err != nil {
+				return nil, parser.LocateError(serrors.ContextualizeErrorf(err, "import (%s)", imprt.Name), modules[i].SourceMap)
+			}
+			if imprt.Section == allSections {
+				imprt.Modules = selected
+			} else {
+				imprt.Module = selected[0]
+			}
+
+			for _, module := range selected {
+				imported[i] = append(imported[i], sectionImport{Name: imprt.Name, Index: sectionIndex(sections, module)})
+			}
+		}
+	}
+
+	return imported, nil
+}
+
+type sectionImport struct {
+	Name  string
+	Index int
+}
+
+func sectionIndex(sections []Module, module *Module) int {
+	for i := range sections {
+		if &sections[i] == module {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// checkSectionCycles finds sections of a module file that import themselves, directly or through other sections.
+func checkSectionCycles(path string, modules []Module, imported [][]sectionImport) error {
+	sectionPath := func(i int) string {
+		return fmt.Sprintf("%s#%d", path, modules[i].Document)
+	}
+
+	// visited is 1 for the sections in chain, and 2 for sections that aren't in any cycle.
+	visited := make([]int, len(modules))
+	chain := []importLink{}
+	var visit func(i int) error
+	visit = func(i int) error {
+		visited[i] = 1
+		for _, imprt := range imported[i] {
+			chain = append(chain, importLink{Path: sectionPath(i), ImportName: imprt.Name})
+			switch visited[imprt.Index] {
+			case 1:
+				start := 0
+				for chain[start].Path != sectionPath(imprt.Index) {
+					start++
+				}
+				cycle := append(append([]importLink{}, chain[start:]...), importLink{Path: sectionPath(imprt.Index)})
+				return parser.LocateError(&ImportCycleError{Chain: cycle}, modules[imprt.Index].SourceMap)
+			case 0:
+				err := visit(imprt.Index)
+				if err != nil {
+					return err
+				}
+			}
+			chain = chain[:len(chain)-1]
+		}
+
+		visited[i] = 2
+		return nil
+	}
+
+	for i := range modules {
+		if visited[i] == 0 {
+			err := visit(i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sectionMatches checks a module's resource kind and (literal) name.
+func sectionMatches(module *Module, kind, name string) bool {
+	moduleKind, val, err := jsonutil.GetOnlyMapEntry(module.Export.Raw)
+	if err != nil || moduleKind != kind {
+		return false
+	}
+	if len(name) == 0 {
+		return true
+	}
+
+	resource, ok := val.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	return resource["name"] == name
+}
+
+// exportValue is the value an import resolves to: the resource of its module,
+// or a list of the resources of each section for an import of all sections.
+func exportValue(imprt *Import) (interface{}, error) {
+	if imprt.Section != allSections {
+		return moduleExportValue(imprt.Module)
+	}
+
+	vals := make([]interface{}, len(imprt.Modules))
+	for i, module := range imprt.Modules {
+		val, err := moduleExportValue(module)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	return vals, nil
+}
+
+func moduleExportValue(module *Module) (interface{}, error) {
+	_, val, err := jsonutil.GetOnlyMapEntry(module.Export.Raw)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, module.Export.Raw, "module should export exactly one top-level key")
+	}
+
+	return val, nil
+}
@@ -0,0 +1,171 @@
+package imports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+)
+
+var sectionModules = map[string]string{
+	"app": `
+params:
+- name: the app name
+  default: app
+deployment:
+  name: ${name}
+---
+service:
+  name: web
+  port: 80
+---
+service:
+  name: admin
+  port: 9090
+`,
+}
+
+func TestImportSections(t *testing.T) {
+	testCases := []struct {
+		imprt, value string
+
+		// expected is the evaluated value, if the import should succeed.
+		expected interface{}
+
+		// expectedErr is empty if the import should succeed.
+		expectedErr string
+	}{
+		{imprt: "app", value: "${svc.name}", expected: "app"},
+		{imprt: "app#0", value: "${svc.name}", expected: "app"},
+		{imprt: "app#2", value: "${svc.port}", expected: float64(9090)},
+		{imprt: "app#deployment", value: "${svc.name}", expected: "app"},
+		{imprt: "app#service/admin", value: "${svc.port}", expected: float64(9090)},
+		{imprt: "app#*", value: "${svc.1.name}", expected: "web"},
+		{
+			imprt: "app#*",
+			value: "\n- first\n- ${svc...}",
+			expected: []interface{}{
+				"first",
+				map[string]interface{}{"name": "app"},
+				map[string]interface{}{"name": "web", "port": float64(80)},
+				map[string]interface{}{"name": "admin", "port": float64(9090)},
+			},
+		},
+		{imprt: "app#3", expectedErr: "module (app) has 3 sections"},
+		{imprt: "app#service", expectedErr: "2 sections of module (app) match"},
+		{imprt: "app#service/db", expectedErr: "no section of module (app) matches"},
+	}
+
+	for i, testCase := range testCases {
+		sources := map[string]string{
+			"app": sectionModules["app"],
+			"root": `
+imports:
+- svc: ` + testCase.imprt + `
+value: ` + testCase.value + `
+`,
+		}
+		evalContext := countingEvalContext(sources, map[string]int{})
+
+		modules, err := evalContext.Parse("root")
+		if len(testCase.expectedErr) > 0 {
+			if err == nil {
+				t.Errorf("case %d: expected an error", i)
+			} else if !strings.Contains(err.Error(), testCase.expectedErr) {
+				t.Errorf("case %d: expected error to contain\n%s\ngot\n%s", i, testCase.expectedErr, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err.Error())
+			continue
+		}
+
+		module := &modules[0]
+		err = evalContext.EvaluateModule(module, nil)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err.Error())
+			continue
+		}
+
+		actual := module.Export.Raw["value"]
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Error(pretty.Sprintf("case %d: evaluated value doesn't match expected\n(%# v)\n(%# v)", i, actual, testCase.expected))
+		}
+	}
+}
+
+func TestSplitImportPath(t *testing.T) {
+	for _, test := range []struct {
+		importPath, path, section string
+	}{
+		{"./app.yaml", "./app.yaml", ""},
+		{"./app.yaml#1", "./app.yaml", "1"},
+		{"https://host/app.yaml#service/web", "https://host/app.yaml", "service/web"},
+		{"./app.yaml#*", "./app.yaml", "*"},
+	} {
+		path, section := SplitImportPath(test.importPath)
+		if path != test.path || section != test.section {
+			t.Errorf("splitting (%s): expected (%s, %s), got (%s, %s)", test.importPath, test.path, test.section, path, section)
+		}
+	}
+}
+
+func TestImportOtherSections(t *testing.T) {
+	sources := map[string]string{
+		"app": `
+imports:
+- svc: ./app#1
+  params:
+    port: 8080
+deployment:
+  name: web
+  port: ${svc.port}
+---
+params:
+- port: the service port
+service:
+  name: web
+  port: ${port}
+`,
+		"cycle": `
+imports:
+- next: ./cycle#1
+deployment:
+  name: web
+---
+imports:
+- all: ./cycle#*
+service:
+  name: web
+`,
+	}
+	evalContext := countingEvalContext(sources, map[string]int{})
+
+	modules, err := evalContext.Parse("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Evaluating the service section doesn't change the section the deployment imports.
+	err = evalContext.EvaluateModule(&modules[1], map[string]interface{}{"port": 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = evalContext.EvaluateModule(&modules[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := modules[0].Export.Raw["deployment"].(map[string]interface{})["port"]
+	if !reflect.DeepEqual(actual, float64(8080)) {
+		t.Errorf("expected the imported section's port param, got %# v", actual)
+	}
+
+	_, err = evalContext.Parse("cycle")
+	expected := "import cycle: cycle#0 -> cycle#1 -> cycle#0 (import names: next, all)"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error to contain\n%s\ngot\n%v", expected, err)
+	}
+}
@@ -17,7 +17,10 @@ func (c *EvalContext) ResolverForModule(module *Module, params map[string]interf
 		if param, ok := params[segments[0]]; ok {
 			val, err := jsonutil.AtPathIn(param, segments[1:])
 			if err != nil {
-				return nil, serrors.InvalidValueContextErrorf(err, param, "resolving %s", ident)
+				return nil, &template.MissingError{
+					Ident: ident,
+					Err:   serrors.InvalidValueContextErrorf(err, param, "resolving %s", ident),
+				}
 			}
 			return val, nil
 		}
@@ -31,18 +34,24 @@ func (c *EvalContext) ResolverForModule(module *Module, params map[string]interf
 					return nil, serrors.ContextualizeErrorf(err, "resolving %s", ident)
 				}
 
-				_, export, err := jsonutil.GetOnlyMapEntry(imprt.Module.Export.Raw)
+				export, err := exportValue(imprt)
 				if err != nil {
-					return nil, serrors.InvalidValueContextErrorf(err, imprt.Module.Export.Raw, "module should export exactly one top-level key")
+					return nil, err
 				}
 				val, err := jsonutil.AtPathIn(export, segments[1:])
 				if err != nil {
-					return nil, serrors.InvalidValueContextErrorf(err, imprt, "resolving %s", ident)
+					return nil, &template.MissingError{
+						Ident: ident,
+						Err:   serrors.InvalidValueContextErrorf(err, imprt, "resolving %s", ident),
+					}
 				}
 				return val, nil
 			}
 		}
 
-		return nil, serrors.InvalidValueErrorf(ident, "invalid template param (%s) for (%s)", ident, module.Path)
+		return nil, &template.MissingError{
+			Ident: ident,
+			Err:   serrors.InvalidValueErrorf(ident, "invalid template param (%s) for (%s)", ident, module.Path),
+		}
 	})
 }
@@ -1,19 +1,41 @@
 package imports
 
+import (
+	"github.com/koki/short/parser"
+)
+
 type Import struct {
 	Name   string
 	Path   string
 	Params map[string]interface{} `json:"Params,omitempty"`
 
+	// Overrides are deep-merged into the imported resource, then Patch is applied to it.
+	Overrides interface{}   `json:"Overrides,omitempty"`
+	Patch     []interface{} `json:"Patch,omitempty"`
+
+	// Section selects a section of a multi-document module (see selectSections).
+	Section string `json:"Section,omitempty"`
+
 	// IsEvaluated have the Params been applied to the Module?
 	IsEvaluated bool `json:"-"`
 
-	Module *Module
+	Module *Module `json:"Module,omitempty"`
+
+	// Modules are all the sections of the module, if Section is "*". Module is nil in that case.
+	Modules []*Module `json:"Modules,omitempty"`
 }
 
 type ParamDef struct {
 	Description string
 	Default     interface{}
+
+	// Type is a builtin type (see ParamTypes) or a koki resource kind (e.g. container).
+	Type     string        `json:"Type,omitempty"`
+	Required bool          `json:"Required,omitempty"`
+	Enum     []interface{} `json:"Enum,omitempty"`
+
+	// Pattern is a regular expression that the whole (string) value must match.
+	Pattern string `json:"Pattern,omitempty"`
 }
 
 type Resource struct {
@@ -29,6 +51,12 @@ type Module struct {
 	Imports []*Import           `json:"Imports,omitempty"`
 	Params  map[string]ParamDef `json:"Params,omitempty"`
 
+	// Document is the index of the module's section in the file at Path.
+	Document int `json:"-"`
+
+	// SourceMap locates errors in the module's section, if its source was mapped. (See parser.LocateError.)
+	SourceMap *parser.SourceMap `json:"-"`
+
 	// IsEvaluated has the Raw yaml in Exports been evaluated (template holes filled, etc)?
 	IsEvaluated bool `json:"-"`
 
@@ -41,6 +69,31 @@ type EvalContext struct {
 	// Get an "absolute" path for a given import.
 	ResolveImportPath func(rootPath string, importPath string) (string, error)
 
-	// Read the contents of a given path.
-	ReadFromPath func(path string) ([]map[string]interface{}, error)
+	// Read the contents of a given path, and the SourceMaps of its documents (or nil, if they aren't mapped).
+	ReadFromPath func(path string) ([]map[string]interface{}, parser.SourceMaps, error)
+
+	// Parse the value of a param as a koki resource kind (e.g. container).
+	// If nil, only builtin param types can be used.
+	ParseParamKind func(kind string, raw interface{}) (interface{}, error)
+
+	// ModuleRoot is the directory that local modules must be in. If it's empty, modules can be anywhere.
+	ModuleRoot string
+
+	// parsing is the chain of modules currently being parsed, for detecting import cycles.
+	parsing []importLink
+
+	// modules caches parsed modules by their contents, so a module imported many times is only parsed once.
+	modules map[moduleCacheKey]cachedModules
+
+	// loaded maps each module file read since the last Reload to its cache key, so a file is only read once.
+	loaded map[string]moduleCacheKey
+
+	// noCache reads and parses every import again, for comparing against the cache in benchmarks.
+	noCache bool
+}
+
+// importLink is a module in a chain of imports, and the name it imports the next module by.
+type importLink struct {
+	Path       string
+	ImportName string
 }
@@ -0,0 +1,126 @@
+package imports
+
+import (
+	"strings"
+
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+// MergeParams deep-merges overrides into a copy of base.
+// Maps are merged key by key, and any other value in overrides replaces the one in base.
+func MergeParams(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, val := range base {
+		merged[key] = copyParamValue(val)
+	}
+
+	for key, val := range overrides {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := val.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = MergeParams(baseMap, overrideMap)
+		} else {
+			merged[key] = copyParamValue(val)
+		}
+	}
+
+	return merged
+}
+
+func copyParamValue(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		return MergeParams(nil, val)
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = copyParamValue(item)
+		}
+		return items
+	default:
+		return val
+	}
+}
+
+// ParamAtPath builds params that set the param at a dotted path (e.g. image.tag) to val.
+func ParamAtPath(path string, val interface{}) (map[string]interface{}, error) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if len(segment) == 0 {
+			return nil, serrors.InvalidValueErrorf(path, "invalid param name")
+		}
+	}
+
+	for i := len(segments) - 1; i > 0; i-- {
+		val = map[string]interface{}{segments[i]: val}
+	}
+
+	return map[string]interface{}{segments[0]: val}, nil
+}
+
+// ParseParamAssignment parses name=value, interpreting the value as yaml (so
+// numbers, bools, lists and maps keep their types).
+func ParseParamAssignment(assignment string) (map[string]interface{}, error) {
+	name, str, err := SplitParamAssignment(assignment)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := ParseParamValue(str)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "param (%s)", name)
+	}
+
+	return ParamAtPath(name, val)
+}
+
+// SplitParamAssignment splits name=value into its name and value.
+func SplitParamAssignment(assignment string) (string, string, error) {
+	i := strings.Index(assignment, "=")
+	if i <= 0 {
+		return "", "", serrors.InvalidValueErrorf(assignment, "expected name=value")
+	}
+
+	return assignment[:i], assignment[i+1:], nil
+}
+
+// ParseParamValue interprets str as a yaml value. Empty strings stay strings.
+func ParseParamValue(str string) (interface{}, error) {
+	if len(strings.TrimSpace(str)) == 0 {
+		return str, nil
+	}
+
+	var val interface{}
+	err := yaml.Unmarshal([]byte(str), &val)
+	if err != nil {
+		return nil, serrors.InvalidValueContextErrorf(err, str, "parsing param value as yaml")
+	}
+
+	return val, nil
+}
+
+// ParamsFromEnv reads params from the environment variables (as from os.Environ)
+// whose names start with prefix, e.g. with prefix SHORT_PARAM_, the variable
+// SHORT_PARAM_replicas=3 sets the param replicas to 3.
+func ParamsFromEnv(environ []string, prefix string) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for _, env := range environ {
+		if !strings.HasPrefix(env, prefix) {
+			continue
+		}
+
+		name, str, err := SplitParamAssignment(strings.TrimPrefix(env, prefix))
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "environment variable (%s)", env)
+		}
+
+		val, err := ParseParamValue(str)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "environment variable (%s%s)", prefix, name)
+		}
+		params[name] = val
+	}
+
+	return params, nil
+}
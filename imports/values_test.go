@@ -0,0 +1,89 @@
+package imports
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeParams(t *testing.T) {
+	base := map[string]interface{}{
+		"image":    map[string]interface{}{"repo": "nginx", "tag": "1.0"},
+		"replicas": float64(2),
+		"ports":    []interface{}{float64(80)},
+	}
+	overrides := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "1.1"},
+		"ports": []interface{}{float64(443)},
+		"env":   "prod",
+	}
+	expected := map[string]interface{}{
+		"image":    map[string]interface{}{"repo": "nginx", "tag": "1.1"},
+		"replicas": float64(2),
+		"ports":    []interface{}{float64(443)},
+		"env":      "prod",
+	}
+
+	merged := MergeParams(base, overrides)
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, got %v", expected, merged)
+	}
+
+	// The inputs aren't modified.
+	if base["image"].(map[string]interface{})["tag"] != "1.0" {
+		t.Errorf("base was modified: %v", base)
+	}
+	merged["image"].(map[string]interface{})["repo"] = "httpd"
+	if base["image"].(map[string]interface{})["repo"] != "nginx" {
+		t.Errorf("merged shares maps with base: %v", base)
+	}
+}
+
+func TestParseParamAssignment(t *testing.T) {
+	testCases := map[string]map[string]interface{}{
+		"replicas=3":       {"replicas": float64(3)},
+		"debug=true":       {"debug": true},
+		"name=":            {"name": ""},
+		"motd=a=b":         {"motd": "a=b"},
+		"image.tag=1.2":    {"image": map[string]interface{}{"tag": float64(1.2)}},
+		"image.tag='1.2'":  {"image": map[string]interface{}{"tag": "1.2"}},
+		"ports=[80, 443]":  {"ports": []interface{}{float64(80), float64(443)}},
+		"labels={app: db}": {"labels": map[string]interface{}{"app": "db"}},
+	}
+	for assignment, expected := range testCases {
+		params, err := ParseParamAssignment(assignment)
+		if err != nil {
+			t.Errorf("%s: %s", assignment, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(params, expected) {
+			t.Errorf("%s: expected %v, got %v", assignment, expected, params)
+		}
+	}
+
+	for _, assignment := range []string{"replicas", "=3", "image..tag=1", "ports=[80"} {
+		_, err := ParseParamAssignment(assignment)
+		if err == nil {
+			t.Errorf("%s: expected an error", assignment)
+		}
+	}
+}
+
+func TestParamsFromEnv(t *testing.T) {
+	environ := []string{
+		"HOME=/root",
+		"SHORT_PARAM_replicas=3",
+		"SHORT_PARAM_env=prod",
+	}
+	expected := map[string]interface{}{
+		"replicas": float64(3),
+		"env":      "prod",
+	}
+
+	params, err := ParamsFromEnv(environ, "SHORT_PARAM_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+}
@@ -2,6 +2,7 @@ package expressions
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,9 +83,14 @@ func UnparseLabelSelector(kubeSelector *metav1.LabelSelector) (string, error) {
 	}
 
 	selectorString := ""
-	// parse through match labels first
-	for k, v := range kubeSelector.MatchLabels {
-		kokiExpr := fmt.Sprintf("%s=%s", k, v)
+	// parse through match labels first, in a stable order
+	keys := make([]string, 0, len(kubeSelector.MatchLabels))
+	for k := range kubeSelector.MatchLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kokiExpr := fmt.Sprintf("%s=%s", k, kubeSelector.MatchLabels[k])
 		if len(selectorString) > 0 {
 			selectorString = fmt.Sprintf("%s&%s", selectorString, kokiExpr)
 		} else {
@@ -1,26 +1,277 @@
 package parser
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 
 	serrors "github.com/koki/structurederrors"
 )
 
+// StreamOpener opens a named input (a file path or a URL) for reading.
+type StreamOpener func(name string) (io.ReadCloser, error)
+
+// URLOpener fetches http:// and https:// inputs.
+type URLOpener struct {
+	Client *http.Client
+
+	// MaxBytes is the largest response body that will be accepted.
+	MaxBytes int64
+}
+
+const (
+	defaultURLTimeout  = 30 * time.Second
+	defaultURLMaxBytes = 10 * 1024 * 1024
+)
+
+// DefaultURLOpener is used by OpenStream for URL inputs.
+var DefaultURLOpener = &URLOpener{
+	Client:   &http.Client{Timeout: defaultURLTimeout},
+	MaxBytes: defaultURLMaxBytes,
+}
+
+// IsURL is true if name should be fetched over http(s) instead of read from disk.
+func IsURL(name string) bool {
+	u, err := url.Parse(name)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// OpenStream opens name as a URL if it has an http(s) scheme, or as a local file otherwise.
+func OpenStream(name string) (io.ReadCloser, error) {
+	if IsURL(name) {
+		return DefaultURLOpener.Open(name)
+	}
+
+	return OpenFile(name)
+}
+
+func OpenFile(name string) (io.ReadCloser, error) {
+	glog.V(5).Infof("opening file %s for reading", name)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "opening file %s", name)
+	}
+
+	return f, nil
+}
+
+// Open reads the whole response body so that network failures and oversized
+// responses are reported here instead of partway through decoding.
+func (o *URLOpener) Open(rawurl string) (io.ReadCloser, error) {
+	glog.V(5).Infof("fetching url %s for reading", rawurl)
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "fetching url %s", rawurl)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, serrors.InvalidValueErrorf(rawurl, "fetching url %s: unexpected response status (%s)", rawurl, resp.Status)
+	}
+
+	body := io.Reader(resp.Body)
+	if o.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, o.MaxBytes+1)
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "reading response from url %s", rawurl)
+	}
+
+	if o.MaxBytes > 0 && int64(len(b)) > o.MaxBytes {
+		return nil, serrors.InvalidValueErrorf(rawurl, "response from url %s is larger than %d bytes", rawurl, o.MaxBytes)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// OpenStreamsFromFiles opens each of the given file paths or http(s) URLs.
 func OpenStreamsFromFiles(filenames []string) ([]io.ReadCloser, error) {
 	readers := []io.ReadCloser{}
 
 	for _, name := range filenames {
-		glog.V(5).Infof("opening file %s for reading", name)
-		f, err := os.Open(name)
+		r, err := OpenStream(name)
 		if err != nil {
-			return nil, serrors.ContextualizeErrorf(err, "opening file %s", name)
+			for _, reader := range readers {
+				reader.Close()
+			}
+			return nil, err
 		}
 
-		readers = append(readers, f)
+		readers = append(readers, r)
 	}
 
 	return readers, nil
 }
+
+// PathOptions controls how directories are expanded into input files.
+type PathOptions struct {
+	// Recursive descends into subdirectories.
+	Recursive bool
+
+	// Include and Exclude are glob patterns (see path.Match) checked against
+	// both the file name and its slash-separated path relative to the directory.
+	// If Include is empty, every manifest file is included.
+	Include []string
+	Exclude []string
+}
+
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// InputFile is a manifest file found by ExpandInputFiles.
+type InputFile struct {
+	// Path is the file path or URL to read.
+	Path string
+
+	// Rel is the slash-separated path of the file relative to the directory
+	// it was found in. Files and URLs that were named directly just use
+	// their base name.
+	Rel string
+}
+
+// ExpandPaths replaces each directory in paths with the manifest files
+// (.yaml, .yml, .json) inside it. Files within a directory are listed in
+// lexical order. Files and URLs are passed through unchanged.
+func ExpandPaths(paths []string, opts PathOptions) ([]string, error) {
+	files, err := ExpandInputFiles(paths, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := make([]string, len(files))
+	for i, file := range files {
+		expanded[i] = file.Path
+	}
+
+	return expanded, nil
+}
+
+// ExpandInputFiles is like ExpandPaths, except that it also records where
+// each file is relative to the directory it was found in.
+func ExpandInputFiles(paths []string, opts PathOptions) ([]InputFile, error) {
+	expanded := []InputFile{}
+	for _, name := range paths {
+		if IsURL(name) {
+			u, _ := url.Parse(name)
+			expanded = append(expanded, InputFile{Path: name, Rel: path.Base(u.Path)})
+			continue
+		}
+
+		info, err := os.Stat(name)
+		if err != nil || !info.IsDir() {
+			// Let the caller report any error when it opens the file.
+			expanded = append(expanded, InputFile{Path: name, Rel: filepath.Base(name)})
+			continue
+		}
+
+		files, err := filesInDir(name, opts)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, files...)
+	}
+
+	return expanded, nil
+}
+
+func filesInDir(dir string, opts PathOptions) ([]InputFile, error) {
+	files := []InputFile{}
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "reading directory %s", dir)
+		}
+
+		if info.IsDir() {
+			if file != dir && !opts.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !manifestExtensions[strings.ToLower(filepath.Ext(file))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return serrors.ContextualizeErrorf(err, "reading directory %s", dir)
+		}
+
+		rel = filepath.ToSlash(rel)
+		matches, err := opts.matches(rel)
+		if err != nil {
+			return err
+		}
+		if matches {
+			glog.V(5).Infof("found file %s in directory %s", file, dir)
+			files = append(files, InputFile{Path: file, Rel: rel})
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+func (opts PathOptions) matches(rel string) (bool, error) {
+	if len(opts.Include) > 0 {
+		included, err := matchesAnyGlob(opts.Include, rel)
+		if err != nil || !included {
+			return false, err
+		}
+	}
+
+	excluded, err := matchesAnyGlob(opts.Exclude, rel)
+	return !excluded, err
+}
+
+func matchesAnyGlob(patterns []string, rel string) (bool, error) {
+	_, name := path.Split(rel)
+	for _, pattern := range patterns {
+		for _, candidate := range []string{rel, name} {
+			matched, err := path.Match(pattern, candidate)
+			if err != nil {
+				return false, serrors.InvalidValueContextErrorf(err, pattern, "invalid glob pattern")
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// OpenStreamsFromPaths is like OpenStreamsFromFiles, except that directories
+// are expanded into the manifest files they contain. (See ExpandPaths.)
+func OpenStreamsFromPaths(paths []string, opts PathOptions) ([]io.ReadCloser, error) {
+	filenames, err := ExpandPaths(paths, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenStreamsFromFiles(filenames)
+}
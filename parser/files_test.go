@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestURLOpener(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pod.yaml":
+			fmt.Fprint(w, "pod:\n  name: nginx\n")
+		case "/big.yaml":
+			fmt.Fprint(w, strings.Repeat("#", 100))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opener := &URLOpener{Client: server.Client(), MaxBytes: 64}
+
+	stream, err := opener.Open(server.URL + "/pod.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "pod:\n  name: nginx\n" {
+		t.Errorf("unexpected contents (%s)", string(b))
+	}
+
+	_, err = opener.Open(server.URL + "/missing.yaml")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got (%v)", err)
+	}
+
+	_, err = opener.Open(server.URL + "/big.yaml")
+	if err == nil || !strings.Contains(err.Error(), "larger than 64 bytes") {
+		t.Errorf("expected a size limit error, got (%v)", err)
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"http://spec.com/pod.yaml":  true,
+		"https://spec.com/pod.yaml": true,
+		"./pod.yaml":                false,
+		"/tmp/pod.yaml":             false,
+		"ftp://spec.com/pod.yaml":   false,
+	} {
+		if IsURL(name) != expected {
+			t.Errorf("IsURL(%s) should be %v", name, expected)
+		}
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "short-expand-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, file := range []string{
+		"b.yaml",
+		"a.json",
+		"notes.txt",
+		"sub/c.yml",
+		"sub/c_test.yaml",
+		"sub/deeper/d.YAML",
+	} {
+		p := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	single := filepath.Join(dir, "notes.txt")
+	for _, test := range []struct {
+		opts     PathOptions
+		expected []string
+	}{
+		{PathOptions{}, []string{"a.json", "b.yaml"}},
+		{PathOptions{Recursive: true}, []string{"a.json", "b.yaml", "sub/c.yml", "sub/c_test.yaml", "sub/deeper/d.YAML"}},
+		{PathOptions{Recursive: true, Exclude: []string{"*_test.yaml", "sub/deeper/*"}}, []string{"a.json", "b.yaml", "sub/c.yml"}},
+		{PathOptions{Recursive: true, Include: []string{"sub/*"}}, []string{"sub/c.yml", "sub/c_test.yaml"}},
+	} {
+		actual, err := ExpandPaths([]string{dir, single}, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{}
+		for _, file := range test.expected {
+			expected = append(expected, filepath.Join(dir, file))
+		}
+		// Explicitly named files are never filtered.
+		expected = append(expected, single)
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%+v: expected\n%v\ngot\n%v", test.opts, expected, actual)
+		}
+	}
+
+	_, err = ExpandPaths([]string{dir}, PathOptions{Include: []string{"["}})
+	if err == nil {
+		t.Error("expected an invalid glob error")
+	}
+
+	files, err := ExpandInputFiles([]string{dir, single, "https://example.com/x/pod.yaml"}, PathOptions{Recursive: true, Include: []string{"sub/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels := []string{}
+	for _, file := range files {
+		rels = append(rels, file.Rel)
+	}
+	expectedRels := []string{"sub/c.yml", "sub/c_test.yaml", "notes.txt", "pod.yaml"}
+	if !reflect.DeepEqual(rels, expectedRels) {
+		t.Errorf("expected relative paths\n%v\ngot\n%v", expectedRels, rels)
+	}
+}
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/koki/json/jsonutil"
+	serrors "github.com/koki/structurederrors"
+)
+
+// PathError is an error about the field at Path in a document, e.g. $.pod.containers.1.env.
+type PathError struct {
+	Path []string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("$.%s: %s", strings.Join(e.Path, "."), e.Err.Error())
+}
+
+// LocatedError is an error whose message has been annotated with positions in its source file.
+type LocatedError struct {
+	Err     error
+	Message string
+
+	// Locations are the positions mentioned in the error and its context, in order.
+	Locations []Location
+}
+
+func (e *LocatedError) Error() string {
+	return e.Message
+}
+
+// Location is a position mentioned in an error, and the $.path it's the position of.
+// Path is empty for the positions of documents and syntax errors.
+type Location struct {
+	Path     string
+	Position Position
+}
+
+// LocateError adds the positions (file:line:column) of the paths in err, which is about
+// the document that sourceMap maps, e.g. "$.pod.containers.1.env (pod.yaml:42:7)".
+// If err hasn't been located and doesn't have any paths, the position of the document itself is added as context.
+//
+// The paths are those of PathErrors and extraneous fields, and the context items that
+// koki/json adds to decoding errors (e.g. $.pod.containers.0.cpu).
+// Paths that have already been located (e.g. in an imported module) are left as they are.
+// If sourceMap is nil (e.g. the document's source couldn't be mapped), err is returned unchanged.
+func LocateError(err error, sourceMap *SourceMap) error {
+	if err == nil || sourceMap == nil {
+		return err
+	}
+
+	located, locations := locate(err, sourceMap)
+	if len(locations) == 0 {
+		root := &LocatedError{Err: err, Message: err.Error(), Locations: []Location{{Position: sourceMap.Root}}}
+		return serrors.ContextualizeErrorf(root, "%s", sourceMap.Root)
+	}
+
+	return withLocations(located, locations)
+}
+
+// Locations lists the positions that LocateError (or ParseSource) added to err, in the order they're mentioned in it.
+// If err hasn't been located, its paths are listed without positions.
+func Locations(err error) []Location {
+	switch e := err.(type) {
+	case *LocatedError:
+		return e.Locations
+	case *serrors.ErrorWithContext:
+		locations := Locations(e.BaseError)
+		if len(locations) > 0 && locations[0].Position.Line > 0 {
+			return locations
+		}
+		for _, contextItem := range e.Context {
+			if path, ok := contextPath(contextItem); ok {
+				locations = append([]Location{{Path: "$." + path}}, locations...)
+			}
+		}
+		return locations
+	case *PathError:
+		return append([]Location{{Path: "$." + strings.Join(e.Path, ".")}}, Locations(e.Err)...)
+	case *jsonutil.ExtraneousFieldsError:
+		locations := make([]Location, len(e.Paths))
+		for i, path := range e.Paths {
+			locations[i].Path = "$." + strings.Join(path, ".")
+		}
+		return locations
+	default:
+		return nil
+	}
+}
+
+// BaseError strips the context, paths, and source positions from err.
+func BaseError(err error) error {
+	for {
+		switch e := err.(type) {
+		case *LocatedError:
+			err = e.Err
+		case *PathError:
+			err = e.Err
+		case *serrors.ErrorWithContext:
+			err = e.BaseError
+		default:
+			return err
+		}
+	}
+}
+
+// locate rebuilds err with each of its paths annotated with its position in sourceMap.
+// Paths are written as context, like the ones from koki/json.
+// It returns the locations in the order they're mentioned in the message.
+func locate(err error, sourceMap *SourceMap) (error, []Location) {
+	switch e := err.(type) {
+	case *serrors.ErrorWithContext:
+		base, locations := locate(e.BaseError, sourceMap)
+		context := make([]string, len(e.Context))
+		for i, contextItem := range e.Context {
+			context[i] = contextItem
+			if path, ok := contextPath(contextItem); ok {
+				pos := sourceMap.Lookup(path)
+				context[i] = fmt.Sprintf("%s (%s)", contextItem, pos)
+				// Context is written from the last item to the first.
+				locations = append([]Location{{Path: contextItem, Position: pos}}, locations...)
+			}
+		}
+
+		return &serrors.ErrorWithContext{BaseError: base, Context: context}, locations
+	case *PathError:
+		path := strings.Join(e.Path, ".")
+		pos := sourceMap.Lookup(path)
+		inner, locations := locate(e.Err, sourceMap)
+		return serrors.ContextualizeErrorf(inner, "$.%s (%s)", path, pos), append([]Location{{Path: "$." + path, Position: pos}}, locations...)
+	case *jsonutil.ExtraneousFieldsError:
+		paths := make([]string, len(e.Paths))
+		locations := make([]Location, len(e.Paths))
+		for i, path := range e.Paths {
+			locations[i] = Location{Path: "$." + strings.Join(path, "."), Position: sourceMap.Lookup(strings.Join(path, "."))}
+			paths[i] = fmt.Sprintf("%s (%s)", locations[i].Path, locations[i].Position)
+		}
+		return &LocatedError{
+			Err:       e,
+			Message:   fmt.Sprintf("extraneous fields (typos?) at paths: %s", strings.Join(paths, ", ")),
+			Locations: locations,
+		}, locations
+	case *LocatedError:
+		// It's already been located.
+		return e, e.Locations
+	default:
+		return err, nil
+	}
+}
+
+// withLocations records locations in the base error of err, so they can be listed with Locations.
+func withLocations(err error, locations []Location) error {
+	switch e := err.(type) {
+	case *serrors.ErrorWithContext:
+		return &serrors.ErrorWithContext{BaseError: withLocations(e.BaseError, locations), Context: e.Context}
+	case *LocatedError:
+		return &LocatedError{Err: e.Err, Message: e.Message, Locations: locations}
+	default:
+		return &LocatedError{Err: err, Message: err.Error(), Locations: locations}
+	}
+}
+
+// contextPath finds the path in a context item that koki/json added to a decoding error, e.g. $.pod.containers.0.cpu.
+// Context items that have already been located (e.g. "$.pod.name (pod.yaml:3:3)") aren't paths.
+func contextPath(contextItem string) (string, bool) {
+	if !strings.HasPrefix(contextItem, "$.") || strings.IndexFunc(contextItem, unicode.IsSpace) >= 0 {
+		return "", false
+	}
+
+	return strings.TrimPrefix(contextItem, "$."), true
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"github.com/koki/json"
+	"github.com/koki/json/jsonutil"
 	"github.com/koki/short/types"
 	"github.com/koki/short/yaml"
 	serrors "github.com/koki/structurederrors"
@@ -158,6 +159,13 @@ func ParseKokiNativeObject(obj interface{}) (interface{}, error) {
 				return nil, serrors.InvalidValueForTypeContextError(err, objMap, namespace)
 			}
 			return namespace, nil
+		case "network_policy":
+			networkPolicy := &types.NetworkPolicyWrapper{}
+			err := json.Unmarshal(bytes, networkPolicy)
+			if err != nil {
+				return nil, serrors.InvalidValueForTypeContextError(err, objMap, networkPolicy)
+			}
+			return networkPolicy, nil
 		case "pdb":
 			pdb := &types.PodDisruptionBudgetWrapper{}
 			err := json.Unmarshal(bytes, pdb)
@@ -228,6 +236,13 @@ func ParseKokiNativeObject(obj interface{}) (interface{}, error) {
 				return nil, serrors.InvalidValueForTypeContextError(err, objMap, replicationController)
 			}
 			return replicationController, nil
+		case "resource_quota":
+			resourceQuota := &types.ResourceQuotaWrapper{}
+			err := json.Unmarshal(bytes, resourceQuota)
+			if err != nil {
+				return nil, serrors.InvalidValueForTypeContextError(err, objMap, resourceQuota)
+			}
+			return resourceQuota, nil
 		case "role":
 			role := &types.RoleWrapper{}
 			err := json.Unmarshal(bytes, role)
@@ -304,6 +319,50 @@ func ParseKokiNativeObject(obj interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// ParseKokiNativeValue parses obj as the koki syntax for a kind of resource,
+// e.g. "pod", or "container" (which has no top-level koki syntax of its own).
+// Fields that don't belong to the kind are an error.
+func ParseKokiNativeValue(kind string, obj interface{}) (interface{}, error) {
+	var data map[string]interface{}
+	var result interface{}
+	switch kind {
+	case "container":
+		objMap, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, serrors.TypeErrorf(obj, "expected a container")
+		}
+
+		bytes, err := json.Marshal(objMap)
+		if err != nil {
+			return nil, serrors.InvalidValueContextErrorf(err, objMap, "error converting to JSON before re-parsing as a container")
+		}
+
+		container := &types.Container{}
+		err = json.Unmarshal(bytes, container)
+		if err != nil {
+			return nil, serrors.InvalidValueForTypeContextError(err, objMap, container)
+		}
+		data, result = objMap, container
+	default:
+		data = map[string]interface{}{kind: obj}
+		var err error
+		result, err = ParseKokiNativeObject(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	extraneousPaths, err := jsonutil.ExtraneousFieldPaths(data, result)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "checking for extraneous fields in %s", kind)
+	}
+	if len(extraneousPaths) > 0 {
+		return nil, &jsonutil.ExtraneousFieldsError{Paths: extraneousPaths}
+	}
+
+	return result, nil
+}
+
 func UnparseKokiNativeObject(kokiObj interface{}) (map[string]interface{}, error) {
 	// Marshal the koki object back into yaml.
 	bytes, err := yaml.Marshal(kokiObj)
@@ -314,8 +373,8 @@ func UnparseKokiNativeObject(kokiObj interface{}) (map[string]interface{}, error
 	obj := map[string]interface{}{}
 	err = yaml.Unmarshal(bytes, &obj)
 	if err != nil {
-		return nil, err
+		return nil, serrors.InvalidInstanceContextErrorf(err, kokiObj, "converting to dictionary")
 	}
 
-	return obj, serrors.InvalidInstanceContextErrorf(err, kokiObj, "converting to dictionary")
+	return obj, nil
 }
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/golang/glog"
 
 	"k8s.io/apimachinery/pkg/util/yaml"
+
+	serrors "github.com/koki/structurederrors"
 )
 
 // Parse reads input files and then returns a deserialized data structure
@@ -17,22 +20,24 @@ func Parse(filenames []string, useStdin bool) ([]map[string]interface{}, error)
 		return nil, fmt.Errorf("can only parse from either stdin or files")
 	}
 
-	var streams []io.ReadCloser
-
 	if useStdin {
 		glog.V(3).Info("reading data from stdin")
-		streams = append(streams, os.Stdin)
-	} else {
-		glog.V(3).Info("reading data from input files")
-		s, err := OpenStreamsFromFiles(filenames)
+		glog.V(3).Info("decoding input data")
+		return ParseStreams([]io.ReadCloser{os.Stdin})
+	}
+
+	glog.V(3).Info("reading data from input files")
+	structs := []map[string]interface{}{}
+	for _, filename := range filenames {
+		// Syntax errors are located in the file.
+		objs, _, err := ReadSource(filename)
 		if err != nil {
 			return nil, err
 		}
-		streams = append(streams, s...)
+		structs = append(structs, objs...)
 	}
 
-	glog.V(3).Info("decoding input data")
-	return ParseStreams(streams)
+	return structs, nil
 }
 
 //parses each stream into a go object and closes the stream once done
@@ -54,11 +59,69 @@ func ParseStreams(streams []io.ReadCloser) ([]map[string]interface{}, error) {
 				return nil, err
 			}
 			if err == nil {
-				structs = append(structs, into)
+				items, err := FlattenList(into)
+				if err != nil {
+					return nil, err
+				}
+				structs = append(structs, items...)
+			}
+		}
+	}
+	return structs, nil
+}
+
+// FlattenList expands a kube-native v1.List or typed list (e.g. DeploymentList)
+// into its items. Any other object is returned as the only item.
+func FlattenList(obj map[string]interface{}) ([]map[string]interface{}, error) {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	if len(apiVersion) == 0 || !strings.HasSuffix(kind, "List") {
+		return []map[string]interface{}{obj}, nil
+	}
+
+	rawItems, ok := obj["items"]
+	if !ok {
+		return []map[string]interface{}{obj}, nil
+	}
+
+	if rawItems == nil {
+		return []map[string]interface{}{}, nil
+	}
+
+	items, ok := rawItems.([]interface{})
+	if !ok {
+		return nil, serrors.InvalidValueErrorf(rawItems, "expected a list of items in %s", kind)
+	}
+
+	// Items in a typed list usually omit their own apiVersion and kind.
+	itemKind := ""
+	if kind != "List" {
+		itemKind = strings.TrimSuffix(kind, "List")
+	}
+
+	structs := []map[string]interface{}{}
+	for i, rawItem := range items {
+		item, ok := rawItem.(map[string]interface{})
+		if !ok {
+			return nil, serrors.ContextualizeErrorf(
+				serrors.InvalidValueErrorf(rawItem, "expected a kube object"), "%s.items[%d]", kind, i)
+		}
+
+		if len(itemKind) > 0 {
+			if _, ok := item["kind"]; !ok {
+				item["kind"] = itemKind
+			}
+			if _, ok := item["apiVersion"]; !ok {
+				item["apiVersion"] = apiVersion
 			}
-			// TBD: Add support for v1.List type by flattening it
-			// and then converting them to individual objects
 		}
+
+		flattened, err := FlattenList(item)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "%s.items[%d]", kind, i)
+		}
+		structs = append(structs, flattened...)
 	}
+
 	return structs, nil
 }
@@ -0,0 +1,400 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	yaml3 "gopkg.in/yaml.v3"
+
+	serrors "github.com/koki/structurederrors"
+)
+
+// Position is a location in a source file. Lines and columns start from 1.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) String() string {
+	if p.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d", p.Filename, p.Line)
+}
+
+// SourceMap maps the fields of one parsed document to their positions in the source file.
+// Paths are written as in error messages, without the leading "$." (e.g. pod.containers.1.env).
+type SourceMap struct {
+	// Root is the position of the document itself.
+	Root Position
+
+	// Comments are the document's comments, in source order.
+	Comments []Comment
+
+	// Source is the document's YAML source. It's nil for JSON documents and the items of kube-native lists.
+	Source []byte
+
+	positions map[string]Position
+}
+
+// CommentKind is where a comment is written, relative to its field.
+type CommentKind int
+
+const (
+	// HeadComment is on the lines before the field.
+	HeadComment CommentKind = iota
+	// LineComment is at the end of the field's line.
+	LineComment
+	// FootComment is on the lines after the field (and its value).
+	FootComment
+)
+
+// Comment is a comment in a source document, and the path of the field it's attached to.
+// The path is empty for comments about the whole document, including the ones before its
+// first field.
+type Comment struct {
+	Path string
+	Kind CommentKind
+
+	// Text is the comment's lines, each starting with "#".
+	Text string
+}
+
+// Lookup finds the position of path, or of its closest ancestor if path
+// isn't in the source. (e.g. It was added by a template.)
+func (m *SourceMap) Lookup(path string) Position {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for len(path) > 0 {
+		if pos, ok := m.positions[path]; ok {
+			return pos
+		}
+
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+
+	return m.Root
+}
+
+// Find finds the position of path, without falling back to an ancestor like Lookup does.
+func (m *SourceMap) Find(path string) (Position, bool) {
+	pos, ok := m.positions[strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")]
+	return pos, ok
+}
+
+// Positions lists the position of each path in the document.
+func (m *SourceMap) Positions() map[string]Position {
+	positions := make(map[string]Position, len(m.positions))
+	for path, pos := range m.positions {
+		positions[path] = pos
+	}
+
+	return positions
+}
+
+// SyntaxError is a YAML syntax error at a known line of the source file.
+type SyntaxError struct {
+	Position Position
+	Err      error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Err.Error())
+}
+
+const yamlSeparator = "---"
+
+var yamlErrorLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// ReadSourceMaps builds a SourceMap for each document that ParseStreams would
+// return for the same data, including the items of kube-native lists.
+func ReadSourceMaps(filename string, data []byte) (SourceMaps, error) {
+	if isJSONStream(data) {
+		// ParseStreams decodes JSON as a stream of concatenated objects.
+		// Only a single object (the common case) is mapped.
+		node := &yaml3.Node{}
+		err := yaml3.Unmarshal(data, node)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "reading source positions from %s", filename)
+		}
+
+		return sourceMapsForDocument(filename, node, 0, nil)
+	}
+
+	maps := []*SourceMap{}
+	for _, chunk := range splitYAMLDocuments(data) {
+		node := &yaml3.Node{}
+		err := yaml3.Unmarshal(chunk.data, node)
+		if err != nil {
+			line := chunk.line
+			if matches := yamlErrorLineRegexp.FindStringSubmatch(err.Error()); len(matches) > 0 {
+				n, _ := strconv.Atoi(matches[1])
+				line += n - 1
+			}
+			return maps, &SyntaxError{
+				Position: Position{Filename: filename, Line: line},
+				Err:      err,
+			}
+		}
+
+		docMaps, err := sourceMapsForDocument(filename, node, chunk.line-1, chunk.data)
+		if err != nil {
+			return maps, err
+		}
+		maps = append(maps, docMaps...)
+	}
+
+	return maps, nil
+}
+
+func isJSONStream(data []byte) bool {
+	trimmed := bytes.TrimLeftFunc(data, unicode.IsSpace)
+	return bytes.HasPrefix(trimmed, []byte("{"))
+}
+
+type yamlChunk struct {
+	// line is where the chunk starts in the file.
+	line int
+	data []byte
+}
+
+// splitYAMLDocuments splits data into documents the same way as the
+// kube-native YAML reader used by ParseStreams.
+func splitYAMLDocuments(data []byte) []yamlChunk {
+	chunks := []yamlChunk{}
+	reader := bufio.NewReader(bytes.NewReader(data))
+	buffer := &bytes.Buffer{}
+	lineNumber, start := 0, 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			lineNumber++
+		}
+
+		if bytes.HasPrefix(line, []byte(yamlSeparator)) &&
+			len(strings.TrimRightFunc(string(line[len(yamlSeparator):]), unicode.IsSpace)) == 0 {
+			if buffer.Len() > 0 {
+				chunks = append(chunks, yamlChunk{line: start, data: append([]byte{}, buffer.Bytes()...)})
+				buffer.Reset()
+			}
+		} else if len(line) > 0 {
+			if buffer.Len() == 0 {
+				start = lineNumber
+			}
+			buffer.Write(line)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if buffer.Len() > 0 {
+		chunks = append(chunks, yamlChunk{line: start, data: buffer.Bytes()})
+	}
+
+	return chunks
+}
+
+// sourceMapsForDocument maps a document, or each of its items if it's a
+// kube-native list. (See FlattenList.) source is the document's YAML source, if it has one.
+func sourceMapsForDocument(filename string, doc *yaml3.Node, lineOffset int, source []byte) ([]*SourceMap, error) {
+	node := doc
+	if node.Kind == yaml3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	obj := map[string]interface{}{}
+	// Errors here are reported when the document is actually parsed.
+	_ = node.Decode(&obj)
+
+	maps, err := flattenSourceMaps(filename, node, obj, lineOffset)
+	if err != nil || len(maps) != 1 {
+		return maps, err
+	}
+	if !isKubeList(obj) {
+		maps[0].Source = source
+	}
+	if node == doc {
+		return maps, nil
+	}
+
+	// Only a single object (not a kube-native list) can own the comments around the document.
+	m := maps[0]
+	if len(doc.HeadComment) > 0 {
+		m.Comments = append([]Comment{{Kind: HeadComment, Text: doc.HeadComment}}, m.Comments...)
+	}
+	m.addComment("", FootComment, doc.FootComment)
+
+	return maps, nil
+}
+
+func flattenSourceMaps(filename string, node *yaml3.Node, obj map[string]interface{}, lineOffset int) ([]*SourceMap, error) {
+	if !isKubeList(obj) {
+		line := node.Line
+		if line == 0 {
+			// Empty documents have no position of their own.
+			line = 1
+		}
+		m := &SourceMap{
+			Root:      Position{Filename: filename, Line: line + lineOffset, Column: node.Column},
+			positions: map[string]Position{},
+		}
+		m.add("", node, filename, lineOffset)
+		return []*SourceMap{m}, nil
+	}
+
+	maps := []*SourceMap{}
+	items, _ := obj["items"].([]interface{})
+	itemNodes := mappingValue(node, "items")
+	for i, item := range items {
+		if itemNodes == nil || i >= len(itemNodes.Content) {
+			break
+		}
+
+		item, _ := item.(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+		}
+		itemMaps, err := flattenSourceMaps(filename, itemNodes.Content[i], item, lineOffset)
+		if err != nil {
+			return nil, err
+		}
+		maps = append(maps, itemMaps...)
+	}
+
+	return maps, nil
+}
+
+// isKubeList checks whether obj is a kube-native list, like FlattenList.
+func isKubeList(obj map[string]interface{}) bool {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	_, hasItems := obj["items"]
+	return len(apiVersion) > 0 && strings.HasSuffix(kind, "List") && hasItems
+}
+
+// mappingValue finds the sequence under key in a mapping node.
+func mappingValue(node *yaml3.Node, key string) *yaml3.Node {
+	if node.Kind != yaml3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			if node.Content[i+1].Kind != yaml3.SequenceNode {
+				return nil
+			}
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func (m *SourceMap) add(path string, node *yaml3.Node, filename string, lineOffset int) {
+	child := func(segment string) string {
+		if len(path) == 0 {
+			return segment
+		}
+		return path + "." + segment
+	}
+
+	switch node.Kind {
+	case yaml3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			keyPath := child(key.Value)
+			m.positions[keyPath] = Position{Filename: filename, Line: key.Line + lineOffset, Column: key.Column}
+
+			// Comments before the first field are about the whole document.
+			headPath := keyPath
+			if len(path) == 0 && i == 0 {
+				headPath = ""
+			}
+			m.addComment(headPath, HeadComment, key.HeadComment)
+			m.addComment(keyPath, LineComment, key.LineComment)
+			m.addComment(keyPath, LineComment, val.LineComment)
+			m.add(keyPath, val, filename, lineOffset)
+			m.addComment(keyPath, FootComment, key.FootComment)
+		}
+	case yaml3.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := child(strconv.Itoa(i))
+			m.positions[itemPath] = Position{Filename: filename, Line: item.Line + lineOffset, Column: item.Column}
+			m.addComment(itemPath, HeadComment, item.HeadComment)
+			m.addComment(itemPath, LineComment, item.LineComment)
+			m.add(itemPath, item, filename, lineOffset)
+			m.addComment(itemPath, FootComment, item.FootComment)
+		}
+	case yaml3.AliasNode:
+		if node.Alias != nil {
+			m.add(path, node.Alias, filename, lineOffset)
+		}
+	}
+}
+
+func (m *SourceMap) addComment(path string, kind CommentKind, text string) {
+	if len(text) > 0 {
+		m.Comments = append(m.Comments, Comment{Path: path, Kind: kind, Text: text})
+	}
+}
+
+// SourceMaps are the SourceMaps of the documents in an input, in the order ParseStreams returns them.
+type SourceMaps []*SourceMap
+
+// Document returns the SourceMap of a document, or nil if it isn't mapped.
+func (maps SourceMaps) Document(document int) *SourceMap {
+	if document < 0 || document >= len(maps) {
+		return nil
+	}
+
+	return maps[document]
+}
+
+// ParseSource parses data like ParseStreams, and returns the SourceMaps of its documents, so
+// errors in them can be located. (See LocateError.) Syntax errors are located in data.
+// The maps are only built from data, so they always match the documents that were parsed.
+// Documents that couldn't be mapped (e.g. all but the first object of a JSON stream) don't have maps.
+func ParseSource(filename string, data []byte) ([]map[string]interface{}, SourceMaps, error) {
+	maps, mapsErr := ReadSourceMaps(filename, data)
+	objs, err := ParseStreams([]io.ReadCloser{ioutil.NopCloser(bytes.NewReader(data))})
+	if err != nil {
+		if syntaxErr, ok := mapsErr.(*SyntaxError); ok {
+			located := &LocatedError{Err: err, Message: err.Error(), Locations: []Location{{Position: syntaxErr.Position}}}
+			return nil, nil, serrors.ContextualizeErrorf(located, "%s", syntaxErr.Position)
+		}
+
+		return nil, nil, err
+	}
+
+	return objs, maps, nil
+}
+
+// ReadSource reads a file (or URL) and parses it with ParseSource.
+func ReadSource(filename string) ([]map[string]interface{}, SourceMaps, error) {
+	stream, err := OpenStream(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stream.Close()
+
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, nil, serrors.ContextualizeErrorf(err, "reading %s", filename)
+	}
+
+	return ParseSource(filename, data)
+}
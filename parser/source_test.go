@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koki/json/jsonutil"
+	serrors "github.com/koki/structurederrors"
+)
+
+var sourceMapsYAML = `# leading comment
+pod:
+  name: a
+  containers:
+  - image: nginx
+  - image: busybox
+    env:
+    - name: X
+---
+
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: s
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: c
+`
+
+func TestReadSourceMaps(t *testing.T) {
+	maps, err := ReadSourceMaps("a.yaml", []byte(sourceMapsYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same documents as ParseStreams.
+	objs, err := ParseStreams([]io.ReadCloser{ioutil.NopCloser(bytes.NewBufferString(sourceMapsYAML))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != len(objs) {
+		t.Fatalf("expected %d source maps, got %d", len(objs), len(maps))
+	}
+
+	for _, test := range []struct {
+		document int
+		path     string
+		expected string
+	}{
+		{0, "$.pod", "a.yaml:2:1"},
+		{0, "$.pod.containers.1", "a.yaml:6:5"},
+		{0, "$.pod.containers.1.env.0.name", "a.yaml:8:7"},
+		{0, "$.pod.containers.1.env.0.value", "a.yaml:8:7"},
+		{1, "$", "a.yaml:10"},
+		{2, "$.metadata.name", "a.yaml:18:5"},
+		{3, "$.kind", "a.yaml:20:3"},
+		{3, "$.nope", "a.yaml:19:3"},
+	} {
+		actual := maps[test.document].Lookup(test.path).String()
+		if actual != test.expected {
+			t.Errorf("document %d %s: expected %s, got %s", test.document, test.path, test.expected, actual)
+		}
+	}
+
+	// Documents keep their source, except for the items of a list.
+	if expected := sourceMapsYAML[:strings.Index(sourceMapsYAML, "---")]; string(maps[0].Source) != expected {
+		t.Errorf("expected the first document's source\n%s\ngot\n%s", expected, string(maps[0].Source))
+	}
+	if maps[2].Source != nil {
+		t.Errorf("expected no source for a list item, got\n%s", string(maps[2].Source))
+	}
+
+	_, err = ReadSourceMaps("b.yaml", []byte("a: 1\n---\nb: [\n  c\n"))
+	if syntaxErr, ok := err.(*SyntaxError); !ok || syntaxErr.Position.Line < 3 {
+		t.Errorf("expected a syntax error in the second document, got %v", err)
+	}
+}
+
+func TestReadSourceMapComments(t *testing.T) {
+	data := `# about the file
+
+# about the pod
+apiVersion: v1 # line of the first field
+kind: Pod
+spec:
+  containers:
+  # the app
+  - name: web
+    # pinned
+    image: nginx:1.13
+    ports:
+    - 80 # http
+  # after the containers
+
+# the end
+`
+	maps, err := ReadSourceMaps("a.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Comment{
+		{Path: "", Kind: HeadComment, Text: "# about the file"},
+		{Path: "", Kind: HeadComment, Text: "# about the pod"},
+		{Path: "apiVersion", Kind: LineComment, Text: "# line of the first field"},
+		{Path: "spec.containers.0", Kind: HeadComment, Text: "# the app"},
+		{Path: "spec.containers.0.image", Kind: HeadComment, Text: "# pinned"},
+		{Path: "spec.containers.0.ports.0", Kind: LineComment, Text: "# http"},
+		{Path: "spec.containers", Kind: FootComment, Text: "# after the containers"},
+		{Path: "", Kind: FootComment, Text: "# the end"},
+	}
+	if !reflect.DeepEqual(maps[0].Comments, expected) {
+		t.Errorf("expected comments\n%#v\ngot\n%#v", expected, maps[0].Comments)
+	}
+
+	if pos, ok := maps[0].Find("$.spec.containers.0.image"); !ok || pos.Line != 11 {
+		t.Errorf("expected to find the image at line 11, got %v (%v)", pos, ok)
+	}
+	if _, ok := maps[0].Find("$.spec.containers.0.missing"); ok {
+		t.Error("expected Find not to fall back to an ancestor")
+	}
+}
+
+func TestLocateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "short-locate-error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a.yaml")
+	if err := ioutil.WriteFile(file, []byte(sourceMapsYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, maps, err := ReadSource(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors are located in the data that was read, not the file as it is now.
+	if err := ioutil.WriteFile(file, []byte("\n\n"+sourceMapsYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = LocateError(&jsonutil.ExtraneousFieldsError{Paths: [][]string{{"pod", "containers", "1", "env"}}}, maps.Document(0))
+	expected := "$.pod.containers.1.env (" + file + ":7:5)"
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected %s in %s", expected, err.Error())
+	}
+	if _, ok := BaseError(err).(*jsonutil.ExtraneousFieldsError); !ok {
+		t.Errorf("expected the original error to be kept, got %#v", BaseError(err))
+	}
+
+	// Already-located paths aren't changed.
+	located := LocateError(err, maps.Document(1))
+	if located.Error() != err.Error() {
+		t.Errorf("expected %s, got %s", err.Error(), located.Error())
+	}
+
+	// Paths from PathErrors, and from the context that koki/json adds to decoding errors.
+	err = LocateError(serrors.ContextualizeErrorf(&PathError{Path: []string{"pod", "containers", "0", "image"}, Err: io.EOF}, "$.pod.name"), maps.Document(0))
+	expected = "$.pod.name (" + file + ":3:3): $.pod.containers.0.image (" + file + ":5:5): EOF"
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %s", expected, err.Error())
+	}
+	locations := Locations(err)
+	if len(locations) != 2 || locations[0].Path != "$.pod.name" || locations[0].Position.Line != 3 ||
+		locations[1].Path != "$.pod.containers.0.image" || locations[1].Position.Line != 5 {
+		t.Errorf("expected the locations of both paths, got %v", locations)
+	}
+
+	// Errors without paths point at the document.
+	err = LocateError(io.EOF, maps.Document(2))
+	if !strings.HasPrefix(err.Error(), file+":15:3") {
+		t.Errorf("expected the document position, got %s", err.Error())
+	}
+
+	// Documents that weren't mapped are left alone.
+	err = LocateError(io.EOF, maps.Document(len(maps)))
+	if err != io.EOF {
+		t.Errorf("expected the original error, got %v", err)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	// Each parse has its own maps, even for the same filename.
+	_, first, err := ParseSource("a.yaml", []byte("pod:\n  name: a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := ParseSource("a.yaml", []byte("# moved\n\npod:\n  name: a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := first.Document(0).Lookup("pod.name"); pos.Line != 2 {
+		t.Errorf("expected line 2 in the first parse, got %s", pos)
+	}
+	if pos := second.Document(0).Lookup("pod.name"); pos.Line != 4 {
+		t.Errorf("expected line 4 in the second parse, got %s", pos)
+	}
+
+	// Syntax errors are located.
+	_, _, err = ParseSource("b.yaml", []byte("a: 1\n---\nb: [\n  c\n"))
+	if err == nil {
+		t.Fatal("expected a syntax error")
+	}
+	locations := Locations(err)
+	if len(locations) != 1 || locations[0].Position.Filename != "b.yaml" || locations[0].Position.Line < 3 {
+		t.Errorf("expected the position of the syntax error, got %v", locations)
+	}
+}
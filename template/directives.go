@@ -0,0 +1,273 @@
+package template
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/koki/json/jsonutil"
+	serrors "github.com/koki/structurederrors"
+)
+
+/*
+
+Directives are maps with special keys that control how a template is filled.
+
+Conditionals choose between two values, and can be used anywhere a value can:
+
+  volumes:
+    if: ${use_cache}
+    then:
+    - name: cache
+      empty_dir: {}
+    else: []          # optional - without it, the field or list item is left out
+
+Loops repeat a template for each item in a list, or each entry in a map:
+
+  containers:
+  - name: app
+  - for_each: ${sidecars}   # a list or map
+    as: sidecar             # optional, the name of the current item (default: item)
+    key_as: i               # optional, the name of its index or key (default: key)
+    do:                     # repeated once for each item
+      name: ${sidecar.name}
+      image: ${sidecar.image}
+
+In a list, the results of "do" are added to the list, like a spread. Elsewhere, they're a new list.
+Use "merge" instead of "do" to merge the results (maps) into a single map:
+
+  labels:
+    for_each: ${extra_labels}
+    merge:
+      ${key}: ${item}
+
+*/
+
+const (
+	ifKey   = "if"
+	thenKey = "then"
+	elseKey = "else"
+
+	forEachKey = "for_each"
+	asKey      = "as"
+	keyAsKey   = "key_as"
+	doKey      = "do"
+	mergeKey   = "merge"
+
+	defaultItemName = "item"
+	defaultKeyName  = "key"
+)
+
+var (
+	ifKeys      = map[string]bool{ifKey: true, thenKey: true, elseKey: true}
+	forEachKeys = map[string]bool{forEachKey: true, asKey: true, keyAsKey: true, doKey: true, mergeKey: true}
+)
+
+func isDirective(template map[string]interface{}, key string, allowedKeys map[string]bool) bool {
+	if _, ok := template[key]; !ok {
+		return false
+	}
+
+	for key := range template {
+		if !allowedKeys[key] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isIfDirective(template map[string]interface{}) bool {
+	return isDirective(template, ifKey, ifKeys)
+}
+
+func isForEachDirective(template map[string]interface{}) bool {
+	return isDirective(template, forEachKey, forEachKeys)
+}
+
+// replaceIf fills the branch of a conditional chosen by its condition.
+// Returns false if there's no branch to use, so the value should be left out.
+func replaceIf(template map[string]interface{}, resolver Resolver, path []string) (interface{}, bool, error) {
+	if _, ok := template[thenKey]; !ok {
+		return nil, false, contextualizePath(serrors.InvalidValueErrorf(template, "expected (%s) with (%s)", thenKey, ifKey), path)
+	}
+
+	condPath := appendPath(path, ifKey)
+	cond, err := replaceAny(template[ifKey], resolver, condPath)
+	if err != nil {
+		return nil, false, err
+	}
+	isTrue, ok := cond.(bool)
+	if !ok {
+		return nil, false, contextualizePath(serrors.InvalidValueErrorf(cond, "expected a bool"), condPath)
+	}
+
+	branch := thenKey
+	if !isTrue {
+		branch = elseKey
+	}
+
+	// Only the chosen branch is filled, so the other can refer to things that don't exist.
+	branchTemplate, ok := template[branch]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return replaceValue(branchTemplate, resolver, appendPath(path, branch))
+}
+
+// replaceForEach fills the body of a loop for each item.
+// Results of "do" are returned as a list, and results of "merge" are merged into a map.
+func replaceForEach(template map[string]interface{}, resolver Resolver, path []string) (interface{}, error) {
+	_, hasDo := template[doKey]
+	_, hasMerge := template[mergeKey]
+	if hasDo == hasMerge {
+		return nil, contextualizePath(serrors.InvalidValueErrorf(template, "expected either (%s) or (%s) with (%s)", doKey, mergeKey, forEachKey), path)
+	}
+
+	itemName, err := loopVariableName(template, asKey, defaultItemName, path)
+	if err != nil {
+		return nil, err
+	}
+	keyName, err := loopVariableName(template, keyAsKey, defaultKeyName, path)
+	if err != nil {
+		return nil, err
+	}
+
+	inputPath := appendPath(path, forEachKey)
+	input, err := replaceAny(template[forEachKey], resolver, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, items, err := loopItems(input)
+	if err != nil {
+		return nil, contextualizePath(err, inputPath)
+	}
+
+	bodyKey := doKey
+	if hasMerge {
+		bodyKey = mergeKey
+	}
+	body := template[bodyKey]
+
+	results := []interface{}{}
+	merged := map[string]interface{}{}
+	for i, item := range items {
+		loopResolver := resolverWithVariables(resolver, map[string]interface{}{
+			itemName: item,
+			keyName:  keys[i],
+		})
+
+		bodyPath := appendPath(path, bodyKey)
+		result, ok, err := replaceValue(body, loopResolver, bodyPath)
+		if err != nil {
+			return nil, serrors.ContextualizeErrorf(err, "%s (%s=%v)", forEachKey, keyName, keys[i])
+		}
+		if !ok {
+			continue
+		}
+
+		if !hasMerge {
+			results = append(results, result)
+			continue
+		}
+
+		entries, ok := result.(map[string]interface{})
+		if !ok {
+			return nil, contextualizePath(serrors.InvalidValueErrorf(result, "expected a map to merge"), bodyPath)
+		}
+		for key, val := range entries {
+			merged[key] = val
+		}
+	}
+
+	if hasMerge {
+		return merged, nil
+	}
+
+	return results, nil
+}
+
+func loopVariableName(template map[string]interface{}, key, defaultName string, path []string) (string, error) {
+	val, ok := template[key]
+	if !ok {
+		return defaultName, nil
+	}
+
+	name, ok := val.(string)
+	if !ok || len(name) == 0 || strings.ContainsAny(name, ".${}") {
+		return "", contextualizePath(serrors.InvalidValueErrorf(val, "expected a name for the loop variable"), appendPath(path, key))
+	}
+
+	return name, nil
+}
+
+// loopItems lists the items of a list (keyed by index) or map (in key order).
+func loopItems(input interface{}) ([]interface{}, []interface{}, error) {
+	switch input := input.(type) {
+	case nil:
+		return nil, nil, nil
+	case []interface{}:
+		keys := make([]interface{}, len(input))
+		for i := range input {
+			keys[i] = float64(i)
+		}
+		return keys, input, nil
+	case map[string]interface{}:
+		names := []string{}
+		for name := range input {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		keys := make([]interface{}, len(names))
+		items := make([]interface{}, len(names))
+		for i, name := range names {
+			keys[i] = name
+			items[i] = input[name]
+		}
+		return keys, items, nil
+	default:
+		return nil, nil, serrors.InvalidValueErrorf(input, "expected a list or map to loop over")
+	}
+}
+
+// resolverWithVariables resolves the given variables (and paths into them), and everything else with resolver.
+func resolverWithVariables(resolver Resolver, variables map[string]interface{}) Resolver {
+	return func(ident string) (interface{}, error) {
+		segments := strings.Split(ident, ".")
+		if variable, ok := variables[segments[0]]; ok {
+			val, err := jsonutil.AtPathIn(variable, segments[1:])
+			if err != nil {
+				return nil, &MissingError{
+					Ident: ident,
+					Err:   serrors.InvalidValueContextErrorf(err, variable, "resolving %s", ident),
+				}
+			}
+			return val, nil
+		}
+
+		return resolver(ident)
+	}
+}
+
+// replaceKey fills the template holes in a map key.
+func replaceKey(key string, resolver Resolver) (string, error) {
+	if !fillRegexp.MatchString(key) {
+		return key, nil
+	}
+
+	val, err := ReplaceString(key, resolver)
+	if err != nil {
+		return "", err
+	}
+	if str, ok := toString(val); ok {
+		return str, nil
+	}
+	if b, ok := val.(bool); ok {
+		return strconv.FormatBool(b), nil
+	}
+
+	return "", serrors.InvalidValueErrorf(val, "expected a string or number for map key (%s)", key)
+}
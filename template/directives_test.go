@@ -0,0 +1,188 @@
+package template
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koki/short/yaml"
+)
+
+var directiveParams = `
+cache: true
+debug: false
+sidecars:
+- name: log
+  image: fluentd
+- name: proxy
+  image: envoy
+labels:
+  tier: web
+  team: infra
+`
+
+func TestDirectives(t *testing.T) {
+	testCases := []struct {
+		template string
+		result   string
+	}{
+		{
+			template: `
+volumes:
+  if: ${cache}
+  then:
+  - name: cache
+logging:
+  if: ${debug}
+  then: verbose
+`,
+			result: `
+volumes:
+- name: cache
+`,
+		},
+		{
+			template: `
+level:
+  if: ${debug}
+  then: verbose
+  else: quiet
+`,
+			result: `
+level: quiet
+`,
+		},
+		{
+			template: `
+containers:
+- name: app
+- if: ${debug}
+  then:
+    name: debugger
+- if: "${debug && cache}"
+  then:
+    name: ${missing}
+  else:
+    name: unused
+- for_each: ${sidecars}
+  as: sidecar
+  key_as: i
+  do:
+    name: ${sidecar.name}-${i}
+    image: ${sidecar.image}
+- name: last
+`,
+			result: `
+containers:
+- name: app
+- name: unused
+- image: fluentd
+  name: log-0
+- image: envoy
+  name: proxy-1
+- name: last
+`,
+		},
+		{
+			template: `
+names:
+  for_each: ${sidecars}
+  do: ${upper(item.name)}
+labels:
+  for_each: ${labels}
+  merge:
+    app: web
+    ${key}: ${item}
+none:
+  for_each: "${missing ?: null}"
+  do: ${item}
+`,
+			result: `
+labels:
+  app: web
+  team: infra
+  tier: web
+names:
+- LOG
+- PROXY
+none: []
+`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		result := replaceYaml(t, testCase.template, directiveParams)
+		if result != strings.Trim(testCase.result, "\n") {
+			t.Errorf("case %d: expected\n%s\ngot\n%s", i, strings.Trim(testCase.result, "\n"), result)
+		}
+	}
+}
+
+func TestDirectiveErrors(t *testing.T) {
+	testCases := map[string]string{
+		"field:\n  if: ${cache}\n":                               "expected (then) with (if)",
+		"field:\n  if: yes please\n  then: x\n":                  "$.field.if",
+		"field:\n  for_each: ${cache}\n  do: x\n":                "expected a list or map to loop over",
+		"field:\n  for_each: ${sidecars}\n":                      "expected either (do) or (merge) with (for_each)",
+		"field:\n  for_each: ${sidecars}\n  merge: ${item.name}": "expected a map to merge",
+		"field:\n  for_each: ${sidecars}\n  do: ${item.port}":    "for_each (key=0)",
+	}
+
+	for template, expected := range testCases {
+		templateObj := map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(template), &templateObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paramsObj := map[string]interface{}{}
+		err = yaml.Unmarshal([]byte(directiveParams), &paramsObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ReplaceMap(templateObj, ResolverForParams(paramsObj))
+		if err == nil {
+			t.Errorf("expected an error for\n%s", template)
+			continue
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing (%s), got\n%s", expected, err.Error())
+		}
+	}
+}
+
+func replaceYaml(t *testing.T, template, params string) string {
+	templateObj := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(template), &templateObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paramsObj := map[string]interface{}{}
+	err = yaml.Unmarshal([]byte(params), &paramsObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resultObj, err := ReplaceMap(templateObj, ResolverForParams(paramsObj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(resultObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Trim(string(b), "\n")
+}
+
+func TestReplaceKey(t *testing.T) {
+	result, err := ReplaceMap(map[string]interface{}{
+		"${name}-${1 + 1}": "x",
+	}, ResolverForParams(map[string]interface{}{"name": "a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"a-2": "x"}) {
+		t.Errorf("unexpected result %v", result)
+	}
+}
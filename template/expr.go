@@ -0,0 +1,814 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	serrors "github.com/koki/structurederrors"
+)
+
+/*
+
+Expressions in template holes, e.g. ${port ?: 8080} or ${upper(name) + "-" + env}
+
+From lowest to highest precedence:
+
+  a ?: b                       a, or b if a is missing (or null)
+  a || b                       logical or
+  a && b                       logical and
+  a == b, !=, <, <=, >, >=     comparisons
+  a + b, a - b                 arithmetic (+ also concatenates strings)
+  a * b, a / b, a % b          arithmetic
+  -a, !a                       negation
+  a.b, a.0, a[b]               indexing
+  f(a, b), (a)                 function calls and grouping
+
+Literals are numbers, 'strings' or "strings", true, false, and null.
+
+A hole that's just a reference (e.g. ${foo.bar.0} or ${some-name}) is passed
+to the Resolver as it is, so names can contain characters like '-' that are
+operators in expressions. A hole that's just a literal (e.g. ${true}, ${8080},
+or ${-1}) isn't a reference.
+
+*/
+
+// MissingError is returned by a Resolver when an identifier has no value.
+// The default operator (?:) only replaces missing values, not other errors.
+type MissingError struct {
+	Ident string
+	Err   error
+}
+
+func (e *MissingError) Error() string {
+	return e.Err.Error()
+}
+
+// IsMissing checks if err (possibly with context) is a MissingError.
+func IsMissing(err error) bool {
+	for {
+		switch e := err.(type) {
+		case *MissingError:
+			return true
+		case *serrors.ErrorWithContext:
+			err = e.BaseError
+		default:
+			return false
+		}
+	}
+}
+
+// Functions can be called in template expressions.
+var Functions = map[string]func(args []interface{}) (interface{}, error){
+	"upper": stringFunction(strings.ToUpper),
+	"lower": stringFunction(strings.ToLower),
+	"trim":  stringFunction(strings.TrimSpace),
+	"base64": stringFunction(func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}),
+	"sha256": stringFunction(func(s string) string {
+		hash := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(hash[:])
+	}),
+	"replace": func(args []interface{}) (interface{}, error) {
+		strs, err := stringArgs(args, 3)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Replace(strs[0], strs[1], strs[2], -1), nil
+	},
+	"join": func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, serrors.InvalidValueErrorf(args, "expected 2 arguments")
+		}
+		list, ok := args[0].([]interface{})
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(args[0], "expected a list")
+		}
+		sep, ok := args[1].(string)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(args[1], "expected a string separator")
+		}
+
+		items := make([]string, len(list))
+		for i, item := range list {
+			str, ok := toString(item)
+			if !ok {
+				return nil, serrors.InvalidValueErrorf(item, "expected a list of strings or numbers")
+			}
+			items[i] = str
+		}
+		return strings.Join(items, sep), nil
+	},
+}
+
+func stringFunction(f func(string) string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		strs, err := stringArgs(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(strs[0]), nil
+	}
+}
+
+func stringArgs(args []interface{}, count int) ([]string, error) {
+	if len(args) != count {
+		return nil, serrors.InvalidValueErrorf(args, "expected %d arguments", count)
+	}
+
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := toString(arg)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(arg, "expected a string or number")
+		}
+		strs[i] = str
+	}
+
+	return strs, nil
+}
+
+var referenceRegexp = regexp.MustCompile(`^[^\s()\[\]+*/%?:<>=!,'"&|]+$`)
+
+// EvalHole evaluates the contents of a template hole (without the ${}).
+func EvalHole(hole string, resolver Resolver) (interface{}, error) {
+	expr, err := parseExpr(hole)
+	if referenceRegexp.MatchString(hole) && (err != nil || !isLiteral(expr)) {
+		return resolver(hole)
+	}
+
+	if err == nil {
+		var val interface{}
+		val, err = expr.eval(resolver)
+		if err == nil {
+			return val, nil
+		}
+	}
+
+	return nil, serrors.ContextualizeErrorf(err, "${%s}", hole)
+}
+
+// isLiteral is true for a literal, or a negated number literal (e.g. -1).
+func isLiteral(e expr) bool {
+	switch e := e.(type) {
+	case *literalExpr:
+		return true
+	case *unaryExpr:
+		literal, ok := e.operand.(*literalExpr)
+		if !ok {
+			return false
+		}
+		_, isNumber := literal.val.(float64)
+		return e.op == "-" && isNumber
+	default:
+		return false
+	}
+}
+
+// Parsing
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+
+	// val is the value of a number or string literal.
+	val interface{}
+
+	// column is where the token starts in the hole, from 1.
+	column int
+}
+
+var operators = []string{"?:", "==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+func tokenize(hole string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(hole)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case unicode.IsDigit(r):
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			// After a '.', a number is a list index, so it can't have a fraction. (e.g. foo.1.2)
+			afterDot := len(tokens) > 0 && tokens[len(tokens)-1].text == "."
+			if !afterDot && i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			text := string(runes[start:i])
+			val, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, syntaxErrorf(hole, start, "invalid number (%s)", text)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, val: val, column: start + 1})
+			continue
+		case r == '_' || unicode.IsLetter(r):
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), column: start + 1})
+			continue
+		case r == '\'' || r == '"':
+			str := []rune{}
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				str = append(str, runes[i])
+			}
+			if i >= len(runes) {
+				return nil, syntaxErrorf(hole, start, "unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start:i]), val: string(str), column: start + 1})
+			continue
+		}
+
+		found := false
+		for _, op := range operators {
+			if strings.HasPrefix(string(runes[i:]), op) {
+				tokens = append(tokens, token{kind: tokenOp, text: op, column: start + 1})
+				i += len([]rune(op))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, syntaxErrorf(hole, start, "unexpected character (%c)", r)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, column: len(runes) + 1}), nil
+}
+
+func syntaxErrorf(hole string, index int, format string, args ...interface{}) error {
+	return serrors.InvalidValueErrorf(hole, "%s at column %d", fmt.Sprintf(format, args...), index+1)
+}
+
+type exprParser struct {
+	hole   string
+	tokens []token
+	pos    int
+}
+
+func parseExpr(hole string) (expr, error) {
+	tokens, err := tokenize(hole)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{hole: hole, tokens: tokens}
+	e, err := p.parseDefault()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("unexpected (%s)", p.peek().text)
+	}
+
+	return e, nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it's one of the given operators.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.errorf("expected (%s)", op)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	if p.peek().kind == tokenEOF {
+		return syntaxErrorf(p.hole, p.peek().column-1, "%s at end of expression", fmt.Sprintf(format, args...))
+	}
+	return syntaxErrorf(p.hole, p.peek().column-1, format, args...)
+}
+
+// parseDefault parses a ?: b, which is right-associative.
+func (p *exprParser) parseDefault() (expr, error) {
+	left, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := p.accept("?:"); ok {
+		right, err := p.parseDefault()
+		if err != nil {
+			return nil, err
+		}
+		return &defaultExpr{value: left, fallback: right}, nil
+	}
+
+	return left, nil
+}
+
+// binaryLevels are the binary operators, from lowest to highest precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (expr, error) {
+	if level >= len(binaryLevels) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(binaryLevels[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (expr, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("["); ok {
+			index, err := p.parseDefault()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expect("]")
+			if err != nil {
+				return nil, err
+			}
+			e = &indexExpr{value: e, index: index}
+		} else if _, ok := p.accept("."); ok {
+			segment, err := p.parseSegment()
+			if err != nil {
+				return nil, err
+			}
+			if ref, ok := e.(*refExpr); ok {
+				ref.path = append(ref.path, segment)
+			} else {
+				e = &indexExpr{value: e, index: &literalExpr{val: segment}}
+			}
+		} else {
+			return e, nil
+		}
+	}
+}
+
+// parseSegment parses the key or list index after a '.'
+func (p *exprParser) parseSegment() (string, error) {
+	t := p.peek()
+	if t.kind == tokenIdent || t.kind == tokenNumber {
+		p.next()
+		return t.text, nil
+	}
+
+	return "", p.errorf("expected a key or index after (.)")
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		p.next()
+		return &literalExpr{val: t.val}, nil
+	case tokenIdent:
+		p.next()
+		switch t.text {
+		case "true":
+			return &literalExpr{val: true}, nil
+		case "false":
+			return &literalExpr{val: false}, nil
+		case "null":
+			return &literalExpr{val: nil}, nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+
+		return &refExpr{path: []string{t.text}}, nil
+	case tokenOp:
+		if t.text == "(" {
+			p.next()
+			e, err := p.parseDefault()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expect(")")
+			if err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	}
+
+	return nil, p.errorf("expected a value")
+}
+
+func (p *exprParser) parseCall(name token) (expr, error) {
+	if _, ok := Functions[name.text]; !ok {
+		return nil, syntaxErrorf(p.hole, name.column-1, "unknown function (%s)", name.text)
+	}
+
+	call := &callExpr{name: name.text}
+	if _, ok := p.accept(")"); ok {
+		return call, nil
+	}
+
+	for {
+		arg, err := p.parseDefault()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+
+		if _, ok := p.accept(")"); ok {
+			return call, nil
+		}
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Evaluation
+
+type expr interface {
+	eval(resolver Resolver) (interface{}, error)
+}
+
+type literalExpr struct {
+	val interface{}
+}
+
+func (e *literalExpr) eval(resolver Resolver) (interface{}, error) {
+	return e.val, nil
+}
+
+// refExpr is a dotted reference, e.g. foo.containers.0
+type refExpr struct {
+	path []string
+}
+
+func (e *refExpr) eval(resolver Resolver) (interface{}, error) {
+	return resolver(strings.Join(e.path, "."))
+}
+
+type defaultExpr struct {
+	value    expr
+	fallback expr
+}
+
+func (e *defaultExpr) eval(resolver Resolver) (interface{}, error) {
+	val, err := e.value.eval(resolver)
+	if err != nil {
+		if !IsMissing(err) {
+			return nil, err
+		}
+	} else if val != nil {
+		return val, nil
+	}
+
+	return e.fallback.eval(resolver)
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+func (e *unaryExpr) eval(resolver Resolver) (interface{}, error) {
+	val, err := e.operand.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "-":
+		n, ok := toNumber(val)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(val, "expected a number for (-)")
+		}
+		return -n, nil
+	default:
+		b, ok := val.(bool)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(val, "expected a bool for (!)")
+		}
+		return !b, nil
+	}
+}
+
+type binaryExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+func (e *binaryExpr) eval(resolver Resolver) (interface{}, error) {
+	left, err := e.left.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit the logical operators.
+	if e.op == "&&" || e.op == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(left, "expected a bool for (%s)", e.op)
+		}
+		if l == (e.op == "||") {
+			return l, nil
+		}
+
+		right, err := e.right.eval(resolver)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(right, "expected a bool for (%s)", e.op)
+		}
+		return r, nil
+	}
+
+	right, err := e.right.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(e.op, left, right)
+	case "+":
+		if l, ok := toNumber(left); ok {
+			if r, ok := toNumber(right); ok {
+				return l + r, nil
+			}
+		}
+		// Concatenate if either side is a string.
+		_, leftIsString := left.(string)
+		_, rightIsString := right.(string)
+		l, lok := toString(left)
+		r, rok := toString(right)
+		if (leftIsString || rightIsString) && lok && rok {
+			return l + r, nil
+		}
+		return nil, serrors.InvalidValueErrorf([]interface{}{left, right}, "expected numbers or strings for (+)")
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, serrors.InvalidValueErrorf([]interface{}{left, right}, "expected numbers for (%s)", e.op)
+	}
+
+	switch e.op {
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, serrors.InvalidValueErrorf(right, "division by zero")
+		}
+		return l / r, nil
+	default:
+		if r == 0 {
+			return nil, serrors.InvalidValueErrorf(right, "division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+}
+
+func compare(op string, left, right interface{}) (interface{}, error) {
+	var cmp int
+	if l, ok := toNumber(left); ok {
+		r, ok := toNumber(right)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(right, "expected a number to compare with (%v)", left)
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	} else if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(right, "expected a string to compare with (%s)", l)
+		}
+		cmp = strings.Compare(l, r)
+	} else {
+		return nil, serrors.InvalidValueErrorf(left, "expected a number or string for (%s)", op)
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type indexExpr struct {
+	value expr
+	index expr
+}
+
+func (e *indexExpr) eval(resolver Resolver) (interface{}, error) {
+	val, err := e.value.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+	index, err := e.index.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch val := val.(type) {
+	case []interface{}:
+		var i float64
+		switch index := index.(type) {
+		case string:
+			n, err := strconv.Atoi(index)
+			if err != nil {
+				return nil, serrors.InvalidValueErrorf(index, "expected a list index")
+			}
+			i = float64(n)
+		default:
+			n, ok := toNumber(index)
+			if !ok || n != math.Trunc(n) {
+				return nil, serrors.InvalidValueErrorf(index, "expected a list index")
+			}
+			i = n
+		}
+		if i < 0 || int(i) >= len(val) {
+			return nil, &MissingError{Ident: fmt.Sprintf("%v", index), Err: serrors.InvalidValueErrorf(index, "index out of range")}
+		}
+		return val[int(i)], nil
+	case map[string]interface{}:
+		key, ok := toString(index)
+		if !ok {
+			return nil, serrors.InvalidValueErrorf(index, "expected a string key")
+		}
+		item, ok := val[key]
+		if !ok {
+			return nil, &MissingError{Ident: key, Err: serrors.InvalidValueErrorf(key, "key not found")}
+		}
+		return item, nil
+	default:
+		return nil, serrors.InvalidValueErrorf(val, "can only index into a list or map")
+	}
+}
+
+type callExpr struct {
+	name string
+	args []expr
+}
+
+func (e *callExpr) eval(resolver Resolver) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		val, err := arg.eval(resolver)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+
+	val, err := Functions[e.name](args)
+	if err != nil {
+		return nil, serrors.ContextualizeErrorf(err, "%s()", e.name)
+	}
+
+	return val, nil
+}
+
+func toNumber(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
+
+// toString converts strings and numbers to strings, the same way they're filled into a template.
+func toString(val interface{}) (string, bool) {
+	if str, ok := val.(string); ok {
+		return str, true
+	}
+	if n, ok := toNumber(val); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	}
+
+	return "", false
+}
+
+func valuesEqual(left, right interface{}) bool {
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return l == r
+		}
+	}
+
+	return reflect.DeepEqual(left, right)
+}
@@ -0,0 +1,136 @@
+package template
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var exprParams = map[string]interface{}{
+	"name":     "web",
+	"port":     float64(80),
+	"replicas": float64(3),
+	"debug":    false,
+	"empty":    nil,
+	"tags":     []interface{}{"a", "b", float64(3)},
+	"image": map[string]interface{}{
+		"repo": "nginx",
+		"tag":  "1.13",
+	},
+	"my-name": "hyphenated",
+}
+
+// exprResolver resolves dotted paths in exprParams.
+func exprResolver(ident string) (interface{}, error) {
+	segments := strings.Split(ident, ".")
+	val, ok := exprParams[segments[0]]
+	if !ok {
+		return ResolverForParams(nil)(ident)
+	}
+	for _, segment := range segments[1:] {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			val = v[segment]
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i >= len(v) {
+				return ResolverForParams(nil)(ident)
+			}
+			val = v[i]
+		default:
+			return ResolverForParams(nil)(ident)
+		}
+	}
+
+	return val, nil
+}
+
+func TestExpressions(t *testing.T) {
+	testCases := map[string]interface{}{
+		"${name}":                                  "web",
+		"${my-name}":                               "hyphenated",
+		"${image.tag}":                             "1.13",
+		"${missing ?: 8080}":                       float64(8080),
+		"${port ?: 8080}":                          float64(80),
+		"${empty ?: 'fallback'}":                   "fallback",
+		"${missing ?: other ?: 'last'}":            "last",
+		"${name + '-' + port}":                     "web-80",
+		"${port + 1}":                              float64(81),
+		"${replicas * 2 - 1}":                      float64(5),
+		"${(replicas + 1) * 2}":                    float64(8),
+		"${7 % replicas}":                          float64(1),
+		"${-port / 4}":                             float64(-20),
+		"${replicas > 2 && !debug}":                true,
+		"${name == 'web' || debug}":                true,
+		"${image.repo != \"nginx\"}":               false,
+		"${'a' < 'b'}":                             true,
+		"${tags[1]}":                               "b",
+		"${tags.0}":                                "a",
+		"${image['repo']}":                         "nginx",
+		"${tags[5] ?: 'none'}":                     "none",
+		"${upper(name)}":                           "WEB",
+		"${lower('ABC')}":                          "abc",
+		"${trim('  x ')}":                          "x",
+		"${replace(image.tag, '.', '-')}":          "1-13",
+		"${join(tags, ',')}":                       "a,b,3",
+		"${base64('hello')}":                       "aGVsbG8=",
+		"${sha256('')}":                            "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"${image.repo}:${image.tag ?: 'latest'}":   "nginx:1.13",
+		"replicas=${replicas + 1}, debug=${debug}": "replicas=4, debug=false",
+
+		// Holes that are only literals aren't looked up as params.
+		"${true}":  true,
+		"${false}": false,
+		"${null}":  nil,
+		"${8080}":  float64(8080),
+		"${1.5}":   float64(1.5),
+		"${-1}":    float64(-1),
+		"${'a-b'}": "a-b",
+		"v${8080}": "v8080",
+	}
+
+	for template, expected := range testCases {
+		val, err := ReplaceString(template, exprResolver)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", template, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(val, expected) {
+			t.Errorf("%s: expected (%#v), got (%#v)", template, expected, val)
+		}
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	testCases := map[string]string{
+		"${port +}":      "at end of expression",
+		"${port + 'a}":   "unterminated string",
+		"${nope(name)}":  "unknown function (nope)",
+		"${port / 0}":    "division by zero",
+		"${name * 2}":    "expected numbers for (*)",
+		"${upper(tags)}": "upper()",
+		"${missing + 1}": "template identifier (missing) not in params",
+		"${(port}":       "expected ())",
+		"${port # 1}":    "unexpected character (#) at column 6",
+		"${!name}":       "expected a bool for (!)",
+		"${tags[name]}":  "expected a list index",
+		"x${port > 'a'}": "expected a number to compare with",
+	}
+
+	for template, expected := range testCases {
+		_, err := ReplaceString(template, exprResolver)
+		if err == nil {
+			t.Errorf("%s: expected an error", template)
+			continue
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing (%s), got\n%s", template, expected, err.Error())
+		}
+		// Errors point at the hole.
+		hole := template[strings.Index(template, "${"):]
+		if !strings.Contains(err.Error(), hole) {
+			t.Errorf("%s: expected error to mention the hole, got\n%s", template, err.Error())
+		}
+	}
+}
@@ -2,9 +2,10 @@ package template
 
 import (
 	"regexp"
+	"sort"
+	"strconv"
 
-	"github.com/kr/pretty"
-
+	"github.com/koki/short/parser"
 	serrors "github.com/koki/structurederrors"
 )
 
@@ -12,12 +13,16 @@ import (
 
 Template "holes" are represented as the string "${NAME}".
 
-If a "hole" is part of (but not all of) a string, then only string/number values are supported.
-This behavior is defined in `generic.fillString`.
+If a "hole" is part of (but not all of) a string, then only string/number/bool values are supported.
+This behavior is defined in `fillString`.
+
+A "hole" can also contain an expression. (See expr.go)
 
 Parameter values may also have document structure and will retain
 this structure when inserted into the template.
 
+Maps can also be directives for conditionals and loops. (See directives.go)
+
 */
 
 // Resolver gets the value to substitute into the template.
@@ -31,7 +36,10 @@ func ResolverForParams(params map[string]interface{}) Resolver {
 			}
 		}
 
-		return nil, serrors.InvalidValueErrorf(params, "template identifier (%s) not in params", ident)
+		return nil, &MissingError{
+			Ident: ident,
+			Err:   serrors.InvalidValueErrorf(params, "template identifier (%s) not in params", ident),
+		}
 	}
 }
 
@@ -40,40 +48,94 @@ func FillTemplate(template interface{}, resolver Resolver) (interface{}, error)
 }
 
 func ReplaceAny(template interface{}, resolver Resolver) (interface{}, error) {
+	return replaceAny(template, resolver, nil)
+}
+
+func ReplaceMap(template map[string]interface{}, resolver Resolver) (map[string]interface{}, error) {
+	return replaceMap(template, resolver, nil)
+}
+
+func ReplaceSlice(template []interface{}, resolver Resolver) ([]interface{}, error) {
+	return replaceSlice(template, resolver, nil)
+}
+
+// The replace* functions track the path to the current value so that errors
+// can say where in the template they happened (e.g. $.pod.containers.0.image).
+
+func replaceAny(template interface{}, resolver Resolver, path []string) (interface{}, error) {
+	val, _, err := replaceValue(template, resolver, path)
+	return val, err
+}
+
+// replaceValue returns false if the value should be left out (see replaceIf).
+func replaceValue(template interface{}, resolver Resolver, path []string) (interface{}, bool, error) {
 	switch template := template.(type) {
 	case string:
-		return ReplaceString(template, resolver)
+		val, err := ReplaceString(template, resolver)
+		if err != nil {
+			return nil, false, contextualizePath(err, path)
+		}
+		return val, true, nil
 	case []interface{}:
-		return ReplaceSlice(template, resolver)
+		val, err := replaceSlice(template, resolver, path)
+		return val, err == nil, err
 	case map[string]interface{}:
-		return ReplaceMap(template, resolver)
+		if isIfDirective(template) {
+			return replaceIf(template, resolver, path)
+		}
+		if isForEachDirective(template) {
+			val, err := replaceForEach(template, resolver, path)
+			return val, err == nil, err
+		}
+		val, err := replaceMap(template, resolver, path)
+		return val, err == nil, err
 	default:
 		// No template parameters in other data types.
 	}
 
-	return template, nil
+	return template, true, nil
 }
 
-func ReplaceMap(template map[string]interface{}, resolver Resolver) (map[string]interface{}, error) {
-	var err error
+func replaceMap(template map[string]interface{}, resolver Resolver, path []string) (map[string]interface{}, error) {
 	newTemplate := map[string]interface{}{}
+	// sourceKeys are the template keys that each new key came from.
+	sourceKeys := map[string]string{}
 	for key, val := range template {
-		newTemplate[key], err = ReplaceAny(val, resolver)
+		valPath := appendPath(path, key)
+		newKey, err := replaceKey(key, resolver)
+		if err != nil {
+			return nil, contextualizePath(err, valPath)
+		}
+
+		newVal, ok, err := replaceValue(val, resolver, valPath)
 		if err != nil {
 			return nil, err
 		}
+		if !ok {
+			continue
+		}
+
+		if otherKey, ok := sourceKeys[newKey]; ok {
+			keys := []string{otherKey, key}
+			sort.Strings(keys)
+			return nil, contextualizePath(serrors.InvalidValueErrorf(newKey, "keys (%s) and (%s) are both filled in as (%s)", keys[0], keys[1], newKey), path)
+		}
+		sourceKeys[newKey] = key
+		newTemplate[newKey] = newVal
 	}
 
 	return newTemplate, nil
 }
 
-func ReplaceSlice(template []interface{}, resolver Resolver) ([]interface{}, error) {
+func replaceSlice(template []interface{}, resolver Resolver, path []string) ([]interface{}, error) {
 	newTemplate := []interface{}{}
-	for _, val := range template {
+	for i, val := range template {
+		itemPath := appendPath(path, strconv.Itoa(i))
+
 		// Spread replacement - append a list of items.
 		newItems, didSpread, err := GetSpread(val, resolver)
 		if err != nil {
-			return nil, err
+			return nil, contextualizePath(err, itemPath)
 		}
 		if didSpread {
 			for _, newItem := range newItems {
@@ -82,23 +144,75 @@ func ReplaceSlice(template []interface{}, resolver Resolver) ([]interface{}, err
 			continue
 		}
 
+		// Loops in lists add each of their results to the list.
+		if loop, ok := val.(map[string]interface{}); ok && isForEachDirective(loop) {
+			if _, ok := loop[doKey]; ok {
+				newItems, err := replaceForEach(loop, resolver, itemPath)
+				if err != nil {
+					return nil, err
+				}
+				newTemplate = append(newTemplate, newItems.([]interface{})...)
+				continue
+			}
+		}
+
 		// Normal replacement - set a single list item.
-		newItem, err := ReplaceAny(val, resolver)
+		newItem, ok, err := replaceValue(val, resolver, itemPath)
 		if err != nil {
 			return nil, err
 		}
-		newTemplate = append(newTemplate, newItem)
+		if ok {
+			newTemplate = append(newTemplate, newItem)
+		}
 	}
 
 	return newTemplate, nil
 }
 
+func appendPath(path []string, segment string) []string {
+	return append(append([]string{}, path...), segment)
+}
+
+func contextualizePath(err error, path []string) error {
+	if len(path) == 0 {
+		return err
+	}
+
+	return &parser.PathError{Path: path, Err: err}
+}
+
 var (
 	spreadRegexp = regexp.MustCompile(`^\$\{([^\{\}]*)\.\.\.\}$`)
 	expandRegexp = regexp.MustCompile(`^\$\{([^\{\}]*)\}$`)
 	fillRegexp   = regexp.MustCompile(`\$\{[^\{\}]*\}`)
 )
 
+// Holes lists every template hole in template (in map keys and string values), sorted.
+// A hole that appears more than once is listed once for each appearance.
+func Holes(template interface{}) []string {
+	holes := []string{}
+	var collect func(val interface{})
+	collect = func(val interface{}) {
+		switch val := val.(type) {
+		case string:
+			holes = append(holes, fillRegexp.FindAllString(val, -1)...)
+		case map[string]interface{}:
+			for key, child := range val {
+				holes = append(holes, fillRegexp.FindAllString(key, -1)...)
+				collect(child)
+			}
+		case []interface{}:
+			for _, child := range val {
+				collect(child)
+			}
+		}
+	}
+	collect(template)
+
+	sort.Strings(holes)
+	return holes
+}
+
 func GetSpread(template interface{}, resolver Resolver) ([]interface{}, bool, error) {
 	if template, ok := template.(string); ok {
 		matches := spreadRegexp.FindStringSubmatch(template)
@@ -106,8 +220,7 @@ func GetSpread(template interface{}, resolver Resolver) ([]interface{}, bool, er
 			return nil, false, nil
 		}
 
-		key := matches[1]
-		val, err := resolver(key)
+		val, err := EvalHole(matches[1], resolver)
 		if err != nil {
 			return nil, false, err
 		}
@@ -143,8 +256,7 @@ func expandString(template string, resolver Resolver) (interface{}, bool, error)
 		return template, false, nil
 	}
 
-	key := matches[1]
-	val, err := resolver(key)
+	val, err := EvalHole(matches[1], resolver)
 	if err != nil {
 		return nil, false, err
 	}
@@ -155,23 +267,22 @@ func expandString(template string, resolver Resolver) (interface{}, bool, error)
 func fillString(template string, resolver Resolver) (string, error) {
 	errors := []error{}
 	result := fillRegexp.ReplaceAllFunc([]byte(template), func(match []byte) []byte {
-		key := match[2 : len(match)-1]
-		val, err := resolver(string(key))
+		hole := string(match[2 : len(match)-1])
+		val, err := EvalHole(hole, resolver)
 		if err != nil {
 			errors = append(errors, err)
+			return match
 		}
 
-		switch val := val.(type) {
-		case string:
-			return []byte(val)
-		case float64:
-			return []byte(pretty.Sprintf("%v", val))
-		case int:
-			return []byte(pretty.Sprintf("%v", val))
-		default:
-			errors = append(errors, serrors.InvalidValueErrorf(val, "expected a string or number for param (%s)", string(key)))
-			return match
+		if str, ok := toString(val); ok {
+			return []byte(str)
+		}
+		if b, ok := val.(bool); ok {
+			return []byte(strconv.FormatBool(b))
 		}
+
+		errors = append(errors, serrors.InvalidValueErrorf(val, "expected a string, number, or bool for param (%s)", hole))
+		return match
 	})
 
 	if len(errors) > 0 {
@@ -1,6 +1,7 @@
 package template
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -89,3 +90,71 @@ func doTest(template, params, result string, t *testing.T) {
 		t.Error("unexpected result")
 	}
 }
+
+func TestTemplateErrorPath(t *testing.T) {
+	template := map[string]interface{}{
+		"pod": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"image": "nginx"},
+				map[string]interface{}{"image": "${missing}"},
+			},
+		},
+	}
+
+	_, err := ReplaceMap(template, ResolverForParams(nil))
+	if err == nil {
+		t.Fatal("expected an error for a missing param")
+	}
+
+	if !strings.Contains(err.Error(), "$.pod.containers.1.image") {
+		t.Errorf("expected the error to include the path of the template hole, got %s", err.Error())
+	}
+}
+
+func TestTemplateDuplicateKeys(t *testing.T) {
+	template := map[string]interface{}{
+		"labels": map[string]interface{}{
+			"${a}": "x",
+			"${b}": "y",
+		},
+	}
+
+	_, err := ReplaceMap(template, ResolverForParams(map[string]interface{}{"a": "app", "b": "app"}))
+	if err == nil {
+		t.Fatal("expected an error for keys that are filled in the same")
+	}
+	if !strings.Contains(err.Error(), "keys (${a}) and (${b}) are both filled in as (app)") || !strings.Contains(err.Error(), "$.labels") {
+		t.Errorf("unexpected error %s", err.Error())
+	}
+
+	result, err := ReplaceMap(template, ResolverForParams(map[string]interface{}{"a": "app", "b": "tier"}))
+	if err != nil || len(result["labels"].(map[string]interface{})) != 2 {
+		t.Errorf("unexpected result %v (%v)", result, err)
+	}
+}
+
+func TestHoles(t *testing.T) {
+	template := map[string]interface{}{
+		"pod": map[string]interface{}{
+			"name": "web-${env}",
+			"containers": []interface{}{
+				map[string]interface{}{"image": "${image}:${tag}"},
+				"${sidecars...}",
+			},
+			"labels": map[string]interface{}{
+				"${key}": "${env}",
+			},
+			"replicas": 3,
+		},
+	}
+
+	expected := []string{"${env}", "${env}", "${image}", "${key}", "${sidecars...}", "${tag}"}
+	actual := Holes(template)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	if holes := Holes(map[string]interface{}{"pod": "web"}); len(holes) != 0 {
+		t.Errorf("expected no holes, got %v", holes)
+	}
+}
@@ -260,6 +260,27 @@ func TestPodTemplates(t *testing.T) {
 	}
 }
 
+func TestNetworkPolicies(t *testing.T) {
+	err := testResource("network_policies", testFuncGenerator(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResourceQuotas(t *testing.T) {
+	err := testResource("resource_quotas", testFuncGenerator(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	err := testResource("lists", testFuncGenerator(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 type filePair struct {
 	kubeSpec   string
 	kokiSpec   string
@@ -0,0 +1,150 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/koki/json"
+	serrors "github.com/koki/structurederrors"
+)
+
+type NetworkPolicyWrapper struct {
+	NetworkPolicy NetworkPolicy `json:"network_policy"`
+}
+
+type NetworkPolicy struct {
+	Version     string            `json:"version,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// PodSelector::metav1.LabelSelector
+	// An empty selector applies the policy to every pod in the namespace.
+	Selector string `json:"selector,omitempty"`
+
+	Ingress     []NetworkPolicyIngressRule `json:"ingress,omitempty"`
+	Egress      []NetworkPolicyEgressRule  `json:"egress,omitempty"`
+	PolicyTypes []PolicyType               `json:"policy_types,omitempty"`
+}
+
+type PolicyType string
+
+const (
+	PolicyTypeIngress PolicyType = "ingress"
+	PolicyTypeEgress  PolicyType = "egress"
+)
+
+type NetworkPolicyIngressRule struct {
+	Ports []NetworkPolicyPort `json:"ports,omitempty"`
+	From  []NetworkPolicyPeer `json:"from,omitempty"`
+}
+
+type NetworkPolicyEgressRule struct {
+	Ports []NetworkPolicyPort `json:"ports,omitempty"`
+	To    []NetworkPolicyPeer `json:"to,omitempty"`
+}
+
+type NetworkPolicyPeer struct {
+	// Pods and Namespaces are label selector expressions.
+	// nil means "not specified", and "" selects everything.
+	Pods       *string `json:"pods,omitempty"`
+	Namespaces *string `json:"namespaces,omitempty"`
+
+	// IPBlock::*IPBlock
+	CIDR   string   `json:"cidr,omitempty"`
+	Except []string `json:"except,omitempty"`
+}
+
+/*
+$protocol://$port
+
+ports:
+  - 80
+  - http
+  - tcp://80
+  - udp://53
+  - tcp://          # all TCP ports
+*/
+type NetworkPolicyPort struct {
+	// Protocol is optional. "" is empty.
+	Protocol Protocol
+
+	// Port is a port number or the name of a containerPort.
+	Port *intstr.IntOrString
+}
+
+var networkPolicyPortRegexp = regexp.MustCompile(`^(udp|tcp)://(.*)$`)
+
+func (p *NetworkPolicyPort) InitFromString(str string) error {
+	matches := networkPolicyPortRegexp.FindStringSubmatch(str)
+	if len(matches) > 0 {
+		p.Protocol = Protocol(matches[1])
+		str = matches[2]
+	}
+
+	if len(str) == 0 {
+		if len(p.Protocol) == 0 {
+			return serrors.InvalidValueForTypeErrorf(str, p, "expected a protocol, a port, or both")
+		}
+
+		return nil
+	}
+
+	port := intstr.Parse(str)
+	p.Port = &port
+	return nil
+}
+
+func (p NetworkPolicyPort) String() string {
+	port := ""
+	if p.Port != nil {
+		port = p.Port.String()
+	}
+
+	if len(p.Protocol) == 0 {
+		return port
+	}
+
+	return fmt.Sprintf("%s://%s", p.Protocol, port)
+}
+
+func (p *NetworkPolicyPort) UnmarshalJSON(data []byte) error {
+	var i int32
+	intErr := json.Unmarshal(data, &i)
+	if intErr == nil {
+		port := intstr.FromInt(int(i))
+		p.Port = &port
+		return nil
+	}
+
+	var s string
+	strErr := json.Unmarshal(data, &s)
+	if strErr != nil {
+		return serrors.InvalidValueForTypeErrorf(string(data), p, "couldn't unmarshal JSON as int or string: (%s), (%s)", intErr.Error(), strErr.Error())
+	}
+
+	return p.InitFromString(s)
+}
+
+func (p NetworkPolicyPort) MarshalJSON() ([]byte, error) {
+	if len(p.Protocol) == 0 && p.Port != nil && p.Port.Type == intstr.Int {
+		b, err := json.Marshal(p.Port.IntVal)
+		if err != nil {
+			return nil, serrors.InvalidInstanceContextErrorf(err, p, "marshalling port number (%d) to JSON", p.Port.IntVal)
+		}
+
+		return b, nil
+	}
+
+	str := p.String()
+	b, err := json.Marshal(str)
+	if err != nil {
+		return nil, serrors.InvalidInstanceContextErrorf(err, p, "marshalling to JSON from string (%s)", str)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+func TestNetworkPolicyPort(t *testing.T) {
+	tryNetworkPolicyPort("80\n", t)
+	tryNetworkPolicyPort("http\n", t)
+	tryNetworkPolicyPort("tcp://80\n", t)
+	tryNetworkPolicyPort("udp://dns\n", t)
+	tryNetworkPolicyPort("tcp://\n", t)
+}
+
+func tryNetworkPolicyPort(s string, t *testing.T) {
+	p := NetworkPolicyPort{}
+	err := yaml.Unmarshal([]byte(s), &p)
+	if err != nil {
+		t.Error(pretty.Sprintf("%s:\n(%s)",
+			serrors.PrettyError(err), s))
+		return
+	}
+
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		t.Error(pretty.Sprintf("%s:\n(%s)\n(%# v)",
+			serrors.PrettyError(err), s, p))
+		return
+	}
+
+	if s != string(b) {
+		t.Error(pretty.Sprintf("round-trip failed:\n(%s)\n(%# v)\n(%s)",
+			s, p, string(b)))
+	}
+}
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/koki/json"
+	serrors "github.com/koki/structurederrors"
+)
+
+type ResourceQuotaWrapper struct {
+	ResourceQuota ResourceQuota `json:"resource_quota"`
+}
+
+type ResourceQuota struct {
+	Version     string            `json:"version,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec::ResourceQuotaSpec
+	Hard   *QuotaResources      `json:"hard,omitempty"`
+	Scopes []ResourceQuotaScope `json:"scopes,omitempty"`
+
+	// Status::ResourceQuotaStatus
+	// Nested because its fields share names with the spec.
+	Status *ResourceQuotaStatus `json:"status,omitempty"`
+}
+
+type ResourceQuotaStatus struct {
+	Hard *QuotaResources `json:"hard,omitempty"`
+	Used *QuotaResources `json:"used,omitempty"`
+}
+
+type ResourceQuotaScope string
+
+const (
+	ResourceQuotaScopeTerminating    ResourceQuotaScope = "terminating"
+	ResourceQuotaScopeNotTerminating ResourceQuotaScope = "not-terminating"
+	ResourceQuotaScopeBestEffort     ResourceQuotaScope = "best-effort"
+	ResourceQuotaScopeNotBestEffort  ResourceQuotaScope = "not-best-effort"
+)
+
+// QuotaResources is a ResourceList where requests.cpu/limits.cpu and
+// requests.memory/limits.memory are folded into the same min/max syntax
+// used for containers. All other resources keep their kube-native names.
+//
+//	hard:
+//	  cpu:
+//	    min: "1"
+//	    max: "2"
+//	  mem:
+//	    min: 1Gi
+//	    max: 2Gi
+//	  pods: 10
+//	  requests.storage: 100Gi
+type QuotaResources struct {
+	CPU       *CPU
+	Mem       *Mem
+	Resources v1.ResourceList
+}
+
+const (
+	quotaResourcesCPUKey = "cpu"
+	quotaResourcesMemKey = "mem"
+)
+
+func (r *QuotaResources) UnmarshalJSON(data []byte) error {
+	obj := map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return serrors.InvalidValueForTypeErrorf(string(data), r, "expected a dictionary of resources")
+	}
+
+	for key, val := range obj {
+		if isJSONObject(val) {
+			switch key {
+			case quotaResourcesCPUKey:
+				r.CPU = &CPU{}
+				err = json.Unmarshal(val, r.CPU)
+			case quotaResourcesMemKey:
+				r.Mem = &Mem{}
+				err = json.Unmarshal(val, r.Mem)
+			default:
+				err = serrors.InvalidValueErrorf(string(val), "only %s and %s accept min/max", quotaResourcesCPUKey, quotaResourcesMemKey)
+			}
+			if err != nil {
+				return serrors.ContextualizeErrorf(err, key)
+			}
+			continue
+		}
+
+		q := resource.Quantity{}
+		err = json.Unmarshal(val, &q)
+		if err != nil {
+			return serrors.ContextualizeErrorf(serrors.InvalidValueForTypeErrorf(string(val), q, "couldn't parse quantity"), key)
+		}
+		if r.Resources == nil {
+			r.Resources = v1.ResourceList{}
+		}
+		r.Resources[v1.ResourceName(key)] = q
+	}
+
+	return nil
+}
+
+func (r QuotaResources) MarshalJSON() ([]byte, error) {
+	obj := map[string]interface{}{}
+	for key, val := range r.Resources {
+		obj[string(key)] = val
+	}
+
+	if r.CPU != nil {
+		obj[quotaResourcesCPUKey] = r.CPU
+	}
+
+	if r.Mem != nil {
+		obj[quotaResourcesMemKey] = r.Mem
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, serrors.InvalidInstanceContextErrorf(err, r, "marshalling resources dictionary to JSON")
+	}
+
+	return b, nil
+}
+
+func isJSONObject(data []byte) bool {
+	for _, c := range data {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+
+	return false
+}
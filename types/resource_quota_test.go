@@ -0,0 +1,47 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+
+	"github.com/koki/short/yaml"
+	serrors "github.com/koki/structurederrors"
+)
+
+func TestQuotaResources(t *testing.T) {
+	tryQuotaResources("pods: \"4\"\n", t, false)
+	tryQuotaResources("cpu:\n  max: \"2\"\n  min: \"1\"\nmem:\n  max: 2Gi\nservices: \"1\"\n", t, false)
+	tryQuotaResources("cpu: \"4\"\nlimits.cpu: \"8\"\n", t, false)
+	tryQuotaResources("pods:\n  max: \"2\"\n", t, true)
+	tryQuotaResources("pods: lots\n", t, true)
+}
+
+func tryQuotaResources(s string, t *testing.T, decodeError bool) {
+	r := QuotaResources{}
+	err := yaml.Unmarshal([]byte(s), &r)
+	if err != nil {
+		if decodeError {
+			return
+		}
+
+		t.Error(pretty.Sprintf("%s:\n(%s)",
+			serrors.PrettyError(err), s))
+		return
+	} else if decodeError {
+		t.Error(pretty.Sprintf("expected a decode error:\n(%s)\n(%# v)", s, r))
+		return
+	}
+
+	b, err := yaml.Marshal(r)
+	if err != nil {
+		t.Error(pretty.Sprintf("%s:\n(%s)\n(%# v)",
+			serrors.PrettyError(err), s, r))
+		return
+	}
+
+	if s != string(b) {
+		t.Error(pretty.Sprintf("round-trip failed:\n(%s)\n(%# v)\n(%s)",
+			s, r, string(b)))
+	}
+}